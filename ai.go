@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"time"
 
@@ -14,7 +15,12 @@ import (
 )
 
 const (
-	eatSizeRequirement = 1.25
+	// splitTravelTime is roughly how long a split cell takes to reach the end
+	// of its split distance.
+	splitTravelTime = 300 * time.Millisecond
+	// chaseMaxLead is the furthest ahead that chase predicts the movement of
+	// its prey.
+	chaseMaxLead = time.Second
 )
 
 const (
@@ -24,11 +30,38 @@ const (
 	stateIdle    = iota
 )
 
+var stateNames = map[byte]string{
+	stateFleeing: "Fleeing",
+	stateHunting: "Hunting",
+	stateFeeding: "Feeding",
+	stateIdle:    "Idle",
+}
+
+// commander sends the bot's commands to the server.
+type commander interface {
+	SetTargetPos(x, y float32)
+	Split()
+	EjectMass()
+	SendNickname(name string)
+}
+
 type AI struct {
 	g *agario.Game
+	c commander
+
+	// params are the parameters in effect for the current update
+	params *aiParams
 
 	Me              *agario.Cell
 	SmallestOwnCell *agario.Cell
+	// PathCell is the own cell that paths are searched from. It's the one in
+	// the most danger, which is usually the smallest.
+	PathCell *agario.Cell
+
+	// Teams is set when playing the teams gamemode. Team is the team that we
+	// were put on.
+	Teams bool
+	Team  team
 
 	State  byte
 	Status []string
@@ -37,18 +70,56 @@ type AI struct {
 	Map         Map
 	DijkstraMap search.Shortest
 
+	clock           time.Duration
 	timeToNextSplit time.Duration
+	timeToNextEject time.Duration
+
+	// splitTimes holds when each of our cells was last split
+	splitTimes map[uint32]time.Duration
+	// MergeIn is how long it will be until all of our cells can merge
+	MergeIn time.Duration
+
+	virusShot *virusShot
+
+	// launchers holds the player that each possible blob of ejected mass
+	// appeared next to, until we know which way the blob is moving. launched
+	// holds the blobs that we saw being ejected.
+	launchers map[uint32]uint32
+	launched  map[uint32]struct{}
+
+	tracker *tracker
+
+	Strategies []namedStrategy
+	Strategy   string
+
+	// cells holds every cell that we can see, in order of ID, so that the
+	// same game always leads to the same decisions.
+	cells []*agario.Cell
 
 	OwnCells []*agario.Cell
 
 	Predators []*agario.Cell
 	Prey      []*agario.Cell
 	Food      []*agario.Cell
-}
+	Viruses   []*agario.Cell
+	Ejected   []*agario.Cell
+	Teammates []*agario.Cell
 
-const foodMaxSize = 20
+	// Threats holds every predator that can reach one of our cells soon.
+	Threats []*predatorThreat
+}
 
 func (ai *AI) Update(dt time.Duration) {
+	ai.clock += dt
+	ai.params = loadAIParams()
+
+	if ai.tracker == nil {
+		ai.tracker = newTracker()
+	}
+	ai.tracker.Update(ai.clock, ai.g.Cells)
+	ai.updateCells()
+	ai.updateLaunchedMass()
+
 	if ai.g.MyIDs == nil || len(ai.g.MyIDs) == 0 {
 		return
 	}
@@ -62,23 +133,35 @@ func (ai *AI) Update(dt time.Duration) {
 	if ai.timeToNextSplit > 0 {
 		ai.timeToNextSplit -= dt
 	}
+	if ai.timeToNextEject > 0 {
+		ai.timeToNextEject -= dt
+	}
 
 	ai.Status = ai.Status[0:0]
 
 	ai.Me = ai.getPseudoMe()
 	ai.SmallestOwnCell = ai.getSmallestOwnCell()
+	ai.updateSplitTimes()
+
+	if ai.Teams {
+		ai.Team = cellTeam(ai.SmallestOwnCell)
+	}
 
 	ai.Predators = ai.Predators[0:0]
 	ai.Prey = ai.Prey[0:0]
 	ai.Food = ai.Food[0:0]
+	ai.Viruses = ai.Viruses[0:0]
+	ai.Ejected = ai.Ejected[0:0]
+	ai.Teammates = ai.Teammates[0:0]
 
-	predatorSize := int32(float32(ai.SmallestOwnCell.Size)*eatSizeRequirement) - 1
+	predatorSize := int32(float32(ai.SmallestOwnCell.Size)*ai.params.EatSizeRequirement) - 1
 
-	preySize := int32(float32(ai.SmallestOwnCell.Size) / eatSizeRequirement)
+	preySize := int32(float32(ai.SmallestOwnCell.Size) / ai.params.EatSizeRequirement)
 	ignoreSize := ai.SmallestOwnCell.Size / 4
 
-	for _, cell := range ai.g.Cells {
+	for _, cell := range ai.cells {
 		if cell.IsVirus {
+			ai.Viruses = append(ai.Viruses, cell)
 			continue
 		}
 
@@ -87,8 +170,12 @@ func (ai *AI) Update(dt time.Duration) {
 		}
 
 		switch {
-		case cell.Size <= 20: // Food. Players start at 10 and can't fall below it.
+		case cell.Size <= ai.params.FoodMaxSize: // Food
 			ai.Food = append(ai.Food, cell)
+		case ai.isEjectedMass(cell):
+			ai.Ejected = append(ai.Ejected, cell)
+		case ai.isTeammate(cell): // Teammates can't eat us and we can't eat them
+			ai.Teammates = append(ai.Teammates, cell)
 		case cell.Size <= preySize && cell.Size >= ignoreSize:
 			ai.Prey = append(ai.Prey, cell)
 		case cell.Size >= predatorSize:
@@ -96,203 +183,191 @@ func (ai *AI) Update(dt time.Duration) {
 		}
 	}
 
+	ai.Threats = ai.getThreats()
+	ai.PathCell = ai.getCellInDanger()
+
 	ai.buildCostMap()
-	ai.DijkstraMap = search.DijkstraFrom(ai.Map.GetNode(gameToCostMap(ai.Me.Position.Elem())), UndirectedMap(ai.Map), nil)
+	ai.DijkstraMap = search.DijkstraFrom(ai.Map.GetNode(gameToCostMap(ai.PathCell.Position.Elem())), UndirectedMap(ai.Map), nil)
 
 	ai.Execute()
 }
 
+// Execute evaluates every enabled strategy and performs the action of the one
+// with the highest priority. Ties go to the strategy listed first.
 func (ai *AI) Execute() {
-	/*if len(ai.Predators) > 0 {
-		isFleeing := ai.flee()
-		if isFleeing {
-			ai.State = stateFleeing
-			return
-		}
-	} else {
-		ai.addStatusMessage("Not fleeing: No known predators")
-	}*/
-	ai.addStatusMessage("Not fleeing: Disabled")
-
-	if len(ai.Prey) > 0 {
-		isHunting := ai.hunt()
-		if isHunting {
-			ai.State = stateHunting
-			return
+	var (
+		best         Action
+		bestName     string
+		bestPriority float64
+	)
+	for _, s := range ai.Strategies {
+		priority, action := s.Evaluate(ai)
+		if action == nil {
+			continue
 		}
 
-		isChasing := ai.chase()
-		if isChasing {
-			ai.State = stateHunting
-			return
+		if best == nil || priority > bestPriority {
+			best = action
+			bestName = s.Name
+			bestPriority = priority
 		}
-	} else {
-		ai.addStatusMessage("Not hunting: No known prey")
-		ai.addStatusMessage("Not chasing: No known prey")
 	}
 
-	if len(ai.Food) > 0 {
-		isFeeding := ai.feed()
-		if isFeeding {
-			ai.State = stateFeeding
-			return
-		}
-	} else {
-		ai.addStatusMessage("Not feeding: No known food")
-	}
-
-	ai.addStatusMessage("Wandering")
-	mapCenter := mgl32.Vec2{float32(ai.g.Board.Bottom) / 2, float32(ai.g.Board.Right) / 2}
-	ai.movePathed(mapCenter)
-	ai.State = stateIdle
-}
-
-// flee moves directly away from the nearest cell that is capable of eating us
-func (ai *AI) flee() bool {
-	canBeSplitKilledBySize := int32((float64(ai.SmallestOwnCell.Size)*eatSizeRequirement - 10) * 2)
-	ignoreSplitKillSize := ai.SmallestOwnCell.Size * 4 // If they're 4x larger than us, they're unlikely to split to kill us
-	closestDangerousPredator := ai.getClosestFiltered(ai.Me.Position, ai.Predators, func(cell *agario.Cell) bool {
-		dist := dist2(ai.Me.Position, cell.Position)
-
-		eatDistance := square((float32(cell.Size) - float32(ai.Me.Size)*0.35) + 100)
-		if dist <= eatDistance {
-			return true
-		}
-		if cell.Size < canBeSplitKilledBySize || cell.Size >= ignoreSplitKillSize {
-			return false
-		}
-
-		splitDistance := cell.SplitDistance()
-		return dist2(ai.Me.Position, cell.Position) <= splitDistance
-	})
-	if closestDangerousPredator == nil {
-		ai.addStatusMessage("Not fleeing: No dangerous predators nearby")
-		return false
-	}
-
-	// Find angle between us and the predator
-	delta := closestDangerousPredator.Position.Sub(ai.Me.Position)
-	angle := math.Atan2(float64(delta.X()), float64(delta.Y()))
-
-	if angle > math.Pi {
-		angle -= math.Pi
-	} else {
-		angle += math.Pi
+	if best == nil {
+		ai.addStatusMessage("Idling: No strategy applies")
+		ai.Strategy = ""
+		ai.State = stateIdle
+		return
 	}
 
-	// Position to move to
-	targetX := ai.Me.Position.X() + float32(500*math.Sin(angle))
-	targetY := ai.Me.Position.Y() + float32(500*math.Cos(angle))
-
-	ai.addStatusMessage("Fleeing from " + prettyCellName(closestDangerousPredator))
-	ai.movePathed(mgl32.Vec2{targetX, targetY})
-
-	return true
+	ai.Strategy = bestName
+	best()
 }
 
 // hunt attempts to kill the closest cell that can be killed by splitting
-func (ai *AI) hunt() bool {
-	if len(ai.OwnCells) > 1 {
+func (ai *AI) hunt() (float64, Action) {
+	if len(ai.Prey) == 0 {
+		ai.addStatusMessage("Not hunting: No known prey")
+		return 0, nil
+	}
+	if 2*len(ai.OwnCells) > maxHuntCells {
 		ai.addStatusMessage("Not hunting: Too many splits")
-		// We won't intentionally split into more than two
-		return false
+		// Splitting could leave us with more than maxHuntCells cells
+		return 0, nil
+	}
+	if ai.recombineSoon() {
+		ai.addStatusMessage("Not hunting: About to recombine")
+		// Splitting now would restart the merge cooldown
+		return 0, nil
 	}
-	if ai.SmallestOwnCell.Size <= 36 {
+
+	// The split piece of our largest cell is the one that does the killing
+	hunter := ai.getLargestOwnCell()
+	if hunter.Size <= 36 {
 		ai.addStatusMessage("Not hunting: Too small")
 		// We can't split unless we have at least 36 mass
-		return false
+		return 0, nil
 	}
 
-	canSplitKillSize := int32(float32(ai.SmallestOwnCell.Size) / 2 / eatSizeRequirement)
-	splitDistance := square(4*(40+(ai.SmallestOwnCell.Speed()*4)) + (float32(ai.SmallestOwnCell.Size) * 1.75))
+	canSplitKillSize := int32(float32(hunter.Size) / 2 / ai.params.EatSizeRequirement)
+	splitDistance := square(4*(40+(hunter.Speed()*4)) + (float32(hunter.Size) * 1.75))
 
-	closestPrey := ai.getClosestFiltered(ai.Me.Position, ai.Prey, func(cell *agario.Cell) bool {
-		return cell.Size <= canSplitKillSize && dist2(ai.Me.Position, cell.Position) < splitDistance
+	closestPrey := ai.getClosestFiltered(hunter.Position, ai.Prey, func(cell *agario.Cell) bool {
+		return cell.Size <= canSplitKillSize && dist2(hunter.Position, cell.Position) < splitDistance
 	})
 	if closestPrey == nil {
 		ai.addStatusMessage("Not hunting: No prey to split kill")
-		return false
+		return 0, nil
 	}
 
-	ai.addStatusMessage("Splitting on " + prettyCellName(closestPrey))
-	ai.Path = []mgl32.Vec2{ai.Me.Position, closestPrey.Position}
-	ai.g.SetTargetPos(closestPrey.Position.X(), closestPrey.Position.Y())
-	if ai.timeToNextSplit <= 0 {
-		ai.g.Split()
-		ai.timeToNextSplit = 250 * time.Millisecond
-	}
+	return priorityNormal, func() {
+		// Aim at where the prey will be once our split cell reaches it
+		target := ai.tracker.PredictPosition(closestPrey, float32(splitTravelTime.Seconds()))
 
-	return true
+		ai.addStatusMessage("Splitting on " + prettyCellName(closestPrey))
+		ai.Path = []mgl32.Vec2{hunter.Position, target}
+		ai.c.SetTargetPos(target.X(), target.Y())
+		if ai.timeToNextSplit <= 0 {
+			ai.c.Split()
+			ai.timeToNextSplit = time.Duration(ai.params.SplitCooldown)
+		}
+		ai.State = stateHunting
+	}
 }
 
 // chase attempts to eat another blob by getting close enough to split on it
-func (ai *AI) chase() bool {
+func (ai *AI) chase() (float64, Action) {
 	/*canKillSize := int16(float64(me.Size) / 2 / eatSizeRequirement)
 	splitDistance := square(4*(40+(ai.getSpeed(me)*4)) + (float64(me.Size) * 1.75))*/
 
+	if ai.recombineSoon() {
+		ai.addStatusMessage("Not chasing: About to recombine")
+		return 0, nil
+	}
+
 	closestPrey := ai.getClosest(ai.Me.Position, ai.Prey)
 	if closestPrey == nil {
 		ai.addStatusMessage("Not chasing: No prey")
-		return false
+		return 0, nil
 	}
 
-	ai.addStatusMessage("Chasing " + prettyCellName(closestPrey))
-	ai.movePathed(closestPrey.Position)
+	return priorityNormal, func() {
+		// Lead the prey by the time it would take us to reach where it is now
+		lead := float32(0)
+		if speed := ai.SmallestOwnCell.Speed() * serverTicksPerSecond; speed > 0 {
+			lead = closestPrey.Position.Sub(ai.Me.Position).Len() / speed
+		}
+		if maxLead := float32(chaseMaxLead.Seconds()); lead > maxLead {
+			lead = maxLead
+		}
 
-	return true
+		ai.addStatusMessage("Chasing " + prettyCellName(closestPrey))
+		ai.movePathed(ai.clampToBoard(ai.tracker.PredictPosition(closestPrey, lead)))
+		ai.State = stateHunting
+	}
 }
 
 // feed attempts to eat the nearest food cell
-func (ai *AI) feed() bool {
+func (ai *AI) feed() (float64, Action) {
 	/*if ai.Me.Size >= 150 {
 		ai.addStatusMessage("Not feeding: Too large")
-		return false
+		return 0, nil
 	}*/
 
 	closestFood := ai.getClosest(ai.Me.Position, ai.Food)
 	if closestFood == nil {
 		ai.addStatusMessage("Not feeding: No food pellets")
-		return false
+		return 0, nil
 	}
 
-	ai.addStatusMessage("Eating food pellets")
-	ai.movePathed(closestFood.Position)
+	return priorityNormal, func() {
+		ai.addStatusMessage("Eating food pellets")
+		ai.movePathed(closestFood.Position)
+		ai.State = stateFeeding
+	}
+}
 
-	return true
+// wander heads towards the center of the map. It always applies, so it is
+// normally listed last.
+func (ai *AI) wander() (float64, Action) {
+	return priorityNormal, func() {
+		ai.addStatusMessage("Wandering")
+		mapCenter := mgl32.Vec2{float32(ai.g.Board.Bottom) / 2, float32(ai.g.Board.Right) / 2}
+		ai.movePathed(mapCenter)
+		ai.State = stateIdle
+	}
 }
 
-const (
+// These can be set by the config file.
+var (
+	// costMapReduction is the number of game units covered by each cell of
+	// the cost map.
 	costMapReduction = 125
 
-	costDoNotPass = 1024
+	// costDoNotPass is the cost of a cell of the cost map that must never be
+	// passed through.
+	costDoNotPass float32 = 1024
 )
 
 func (ai *AI) buildCostMap() {
-	w, h := int(ai.g.Board.Right/costMapReduction), int(ai.g.Board.Bottom/costMapReduction)
+	w, h := int(ai.g.Board.Right)/costMapReduction, int(ai.g.Board.Bottom)/costMapReduction
 	ai.Map = NewMap(w+1, h+1)
 
-	for x := 0; x < w; x++ {
-		ai.Map[x][0] = costDoNotPass / 2
-		ai.Map[x][1] += costDoNotPass / 3
-		ai.Map[x][2] += costDoNotPass / 4
-		ai.Map[x][3] += costDoNotPass / 5
-
-		ai.Map[x][h] = costDoNotPass / 2
-		ai.Map[x][h-1] = costDoNotPass / 3
-		ai.Map[x][h-2] = costDoNotPass / 4
-		ai.Map[x][h-3] = costDoNotPass / 5
-	}
+	for i, fraction := range ai.params.BorderCosts {
+		if i > w/2 || i > h/2 {
+			break
+		}
+		cost := costDoNotPass * fraction
 
-	for y := 0; y < h; y++ {
-		ai.Map[0][y] = costDoNotPass / 2
-		ai.Map[1][y] = costDoNotPass / 3
-		ai.Map[2][y] = costDoNotPass / 4
-		ai.Map[3][y] = costDoNotPass / 5
+		for x := 0; x < w; x++ {
+			ai.Map[x][i] = cost
+			ai.Map[x][h-i] = cost
+		}
 
-		ai.Map[w][y] = costDoNotPass / 2
-		ai.Map[w-1][y] = costDoNotPass / 3
-		ai.Map[w-2][y] = costDoNotPass / 4
-		ai.Map[w-3][y] = costDoNotPass / 5
+		for y := 0; y < h; y++ {
+			ai.Map[i][y] = cost
+			ai.Map[w-i][y] = cost
+		}
 	}
 
 	//canBeSplitKilledBySize := int32((float64(ai.SmallestOwnCell.Size)*eatSizeRequirement - 10) * 2)
@@ -307,10 +382,12 @@ func (ai *AI) buildCostMap() {
 			setCostMapCircle(ai.Map, x+1, y+1, splitDistance, costDoNotPass/2)
 		}*/
 
-		size := (int(cell.Size) + 100) / costMapReduction
+		size := (int(cell.Size) + ai.params.PredatorPadding) / costMapReduction
 		setCostMapCircle(ai.Map, x, y, size, costDoNotPass)
 		setCostMapCircle(ai.Map, x+1, y+1, size, costDoNotPass)
 	}
+
+	ai.addVirusCosts()
 }
 
 func setCostMapLine(m Map, x1, y1, x2, y2 int, value float32) {
@@ -522,13 +599,28 @@ func (ai *AI) getSmallestOwnCell() *agario.Cell {
 			continue
 		}
 
-		if smallest.Size < cell.Size || (smallest.Size == cell.Size && cell.ID < smallest.ID) {
+		if cell.Size < smallest.Size || (smallest.Size == cell.Size && cell.ID < smallest.ID) {
 			smallest = cell
 		}
 	}
 	return smallest
 }
 
+func (ai *AI) getLargestOwnCell() *agario.Cell {
+	var largest *agario.Cell
+	for _, cell := range ai.OwnCells {
+		if largest == nil {
+			largest = cell
+			continue
+		}
+
+		if cell.Size > largest.Size || (largest.Size == cell.Size && cell.ID < largest.ID) {
+			largest = cell
+		}
+	}
+	return largest
+}
+
 func (ai *AI) getOurTotalSize() (s int32) {
 	for _, cell := range ai.OwnCells {
 		s += cell.Size
@@ -545,6 +637,20 @@ func (ai *AI) updateOwnCells() {
 		}
 		ai.OwnCells = append(ai.OwnCells, cell)
 	}
+	sortByID(ai.OwnCells)
+}
+
+func (ai *AI) updateCells() {
+	ai.cells = ai.cells[0:0]
+	for _, cell := range ai.g.Cells {
+		ai.cells = append(ai.cells, cell)
+	}
+	sortByID(ai.cells)
+}
+
+// sortByID sorts cells in increasing order of ID.
+func sortByID(cells []*agario.Cell) {
+	sort.Slice(cells, func(i, j int) bool { return cells[i].ID < cells[j].ID })
 }
 
 func (ai *AI) addStatusMessage(str string) {
@@ -571,13 +677,13 @@ func (ai *AI) addStatusMessage(str string) {
 }*/
 
 func (ai *AI) movePathed(position mgl32.Vec2) {
-	minDistance2 := square(float32(ai.Me.Size) + costMapReduction*1.3)
+	minDistance2 := square(float32(ai.PathCell.Size) + float32(costMapReduction)*1.3)
 
-	if dist2(ai.Me.Position, position) < minDistance2 {
+	if dist2(ai.PathCell.Position, position) < minDistance2 {
 		ai.addStatusMessage("Objective is within minimum distance. Moving directly to objective.")
 
-		ai.Path = []mgl32.Vec2{ai.Me.Position, position}
-		ai.g.SetTargetPos(position.X(), position.Y())
+		ai.Path = []mgl32.Vec2{ai.PathCell.Position, position}
+		ai.setTargetPos(position)
 		return
 	}
 
@@ -598,8 +704,8 @@ func (ai *AI) movePathed(position mgl32.Vec2) {
 	if path == nil {
 		ai.addStatusMessage("movePathed: Failed to find path. Moving directly to objective.")
 
-		ai.Path = []mgl32.Vec2{ai.Me.Position, position}
-		ai.g.SetTargetPos(position.X(), position.Y())
+		ai.Path = []mgl32.Vec2{ai.PathCell.Position, position}
+		ai.setTargetPos(position)
 		return
 	}
 
@@ -609,15 +715,15 @@ func (ai *AI) movePathed(position mgl32.Vec2) {
 }
 
 func (ai *AI) moveAlongPath(targetPosition mgl32.Vec2, path []graph.Node) {
-	minDistance2 := square(float32(ai.Me.Size) + costMapReduction*1.3)
+	minDistance2 := square(float32(ai.PathCell.Size) + float32(costMapReduction)*1.3)
 
 	var pathNode *mapNode
 	var pathVecs []mgl32.Vec2
 	for _, rawNode := range path {
 		node := rawNode.(*mapNode)
-		pos := mgl32.Vec2{float32(node.X) * costMapReduction, float32(node.Y) * costMapReduction}
+		pos := mgl32.Vec2{float32(node.X * costMapReduction), float32(node.Y * costMapReduction)}
 
-		if pathNode == nil && dist2(ai.Me.Position, pos) >= minDistance2 {
+		if pathNode == nil && dist2(ai.PathCell.Position, pos) >= minDistance2 {
 			pathNode = node
 		}
 
@@ -627,13 +733,13 @@ func (ai *AI) moveAlongPath(targetPosition mgl32.Vec2, path []graph.Node) {
 	if pathNode == nil {
 		ai.addStatusMessage("Failed to find path node that was far enough away. Moving directly to objective.")
 
-		ai.Path = []mgl32.Vec2{ai.Me.Position, targetPosition}
-		ai.g.SetTargetPos(targetPosition.X(), targetPosition.Y())
+		ai.Path = []mgl32.Vec2{ai.PathCell.Position, targetPosition}
+		ai.setTargetPos(targetPosition)
 		return
 	}
 
 	ai.Path = pathVecs
-	ai.g.SetTargetPos(float32(pathNode.X*costMapReduction), float32(pathNode.Y*costMapReduction))
+	ai.setTargetPos(mgl32.Vec2{float32(pathNode.X * costMapReduction), float32(pathNode.Y * costMapReduction)})
 }
 
 func dist2(a, b mgl32.Vec2) float32 {
@@ -659,5 +765,5 @@ func costMapToGame(x, y int) (float32, float32) {
 }
 
 func gameToCostMap(x, y float32) (int, int) {
-	return int(x / costMapReduction), int(y / costMapReduction)
+	return int(x) / costMapReduction, int(y) / costMapReduction
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// disconnectTimeout is how long we wait for a message before deciding
+	// that the connection has been lost.
+	disconnectTimeout = 10 * time.Second
+
+	reconnectMinBackoff = time.Second
+	reconnectMaxBackoff = time.Minute
+)
+
+// botLogger writes to the standard logger, prefixing every message with the
+// name of the bot that it came from.
+type botLogger struct {
+	prefix string
+}
+
+func (l botLogger) Printf(format string, v ...interface{}) {
+	log.Output(2, l.prefix+fmt.Sprintf(format, v...))
+}
+
+// bot is a single player. It plays one session at a time with its own
+// connection, keepAlive and AI, and opens a new session whenever the last one
+// ends.
+type bot struct {
+	Name string
+
+	open       func(stop <-chan struct{}) (*session, error)
+	strategies []namedStrategy
+	log        botLogger
+
+	mu    sync.Mutex
+	game  *agario.Game
+	ai    *AI
+	lives lifeTracker
+}
+
+// newBot creates a bot that opens its sessions with open, which gives up with
+// errStopped once stop is closed. If name isn't empty,
+// it prefixes everything the bot logs.
+func newBot(name string, open func(stop <-chan struct{}) (*session, error), strategies []namedStrategy) *bot {
+	b := &bot{
+		Name: name,
+
+		open:       open,
+		strategies: strategies,
+	}
+	if name != "" {
+		b.log.prefix = "[" + name + "] "
+	}
+	return b
+}
+
+// Current returns the game that the bot is currently playing and the AI that
+// plays it, or nils if it isn't connected. The AI must only be used while the
+// game is locked.
+func (b *bot) Current() (*agario.Game, *AI) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.game, b.ai
+}
+
+func (b *bot) setCurrent(g *agario.Game, ai *AI) {
+	b.mu.Lock()
+	b.game, b.ai = g, ai
+	b.mu.Unlock()
+}
+
+// Run plays sessions until quit is closed or there are no more sessions,
+// backing off exponentially while they fail to open.
+func (b *bot) Run(quit <-chan struct{}) {
+	backoff := reconnectMinBackoff
+	for {
+		s, err := b.open(quit)
+		if err == errNoMoreSessions {
+			b.log.Printf("No more sessions")
+			return
+		}
+		if err == errStopped {
+			return
+		}
+		if err != nil {
+			b.log.Printf("Unable to start session: %s", err)
+			b.log.Printf("Retrying in %s", backoff)
+
+			select {
+			case <-quit:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+			continue
+		}
+		backoff = reconnectMinBackoff
+
+		stopped := b.runSession(s, quit)
+		b.setCurrent(nil, nil)
+		s.Close()
+
+		b.mu.Lock()
+		b.lives.End()
+		b.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		b.log.Printf("Disconnected. Reconnecting...")
+	}
+}
+
+// LogSummary logs how every life of the bot went.
+func (b *bot) LogSummary() {
+	b.mu.Lock()
+	lives := b.lives.Lives()
+	b.mu.Unlock()
+
+	b.log.Printf("Session summary:")
+	logLives(b.log.Printf, lives)
+}
+
+// runSession drives keepAlive and a fresh AI from the events of s until the
+// session ends. It reports whether it stopped because quit was closed.
+func (b *bot) runSession(s *session, quit <-chan struct{}) bool {
+	ig := s.Game
+
+	ai := &AI{
+		g: ig,
+		c: s.Commander,
+
+		Teams: *gamemode == "teams",
+
+		Strategies: b.strategies,
+	}
+	ka := &keepAlive{
+		g:   ig,
+		c:   s.Commander,
+		log: b.log,
+	}
+
+	b.setCurrent(ig, ai)
+
+	gameEvents := make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		s.Events(gameEvents, stop)
+		close(done)
+	}()
+
+	watchdog := time.NewTimer(disconnectTimeout)
+	defer watchdog.Stop()
+
+	clock := s.Clock
+	if clock == nil {
+		start := time.Now()
+		clock = func() time.Duration { return time.Since(start) }
+	}
+
+	lastTick := clock()
+	for {
+		select {
+		case <-quit:
+			return true
+		case <-done:
+			return false
+		case <-watchdog.C:
+			b.log.Printf("No messages for %s", disconnectTimeout)
+			return false
+		case <-gameEvents:
+			watchdog.Reset(disconnectTimeout)
+
+			ig.Lock()
+
+			now := clock()
+			dt := now - lastTick
+			lastTick = now
+
+			ka.Update(dt)
+			ai.Update(dt)
+
+			b.mu.Lock()
+			b.lives.Update(dt, ka.currentlyAlive(), ownMass(ig))
+			b.mu.Unlock()
+
+			ig.Unlock()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/ajhager/engi"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// cameraSmoothTime is roughly how long the camera takes to catch up with
+	// our cells.
+	cameraSmoothTime = 150 * time.Millisecond
+
+	// freeCameraSpeed is how fast the free camera pans, in pixels per second.
+	freeCameraSpeed = 800
+	// freeCameraZoomStep is how much a step of the mouse wheel zooms.
+	freeCameraZoomStep = 1.1
+	freeCameraMinZoom  = 0.02
+	freeCameraMaxZoom  = 4
+)
+
+// camera is what part of the game is drawn. It normally follows our cells, but
+// it can be switched to a free camera that is moved with WASD and zoomed with
+// the mouse wheel.
+type camera struct {
+	// Center is the position in the game that is drawn in the centre of the
+	// screen. Zoom is how many pixels a unit of the game takes up.
+	Center mgl32.Vec2
+	Zoom   float32
+
+	Free bool
+
+	placed     bool
+	lastUpdate time.Time
+	held       map[engi.Key]bool
+}
+
+// Key toggles the free camera and keeps track of the keys that move it.
+func (c *camera) Key(key engi.Key, action engi.Action) {
+	if c.held == nil {
+		c.held = make(map[engi.Key]bool)
+	}
+
+	switch action {
+	case engi.PRESS:
+		c.held[key] = true
+	case engi.RELEASE:
+		c.held[key] = false
+		return
+	default:
+		return
+	}
+
+	if key == engi.F {
+		c.Free = !c.Free
+		if c.Zoom == 0 {
+			c.Zoom = 1
+		}
+		if c.Free {
+			log.Printf("Free camera: WASD to move, mouse wheel to zoom, F to follow")
+		} else {
+			log.Printf("Following our cells")
+		}
+	}
+}
+
+// Scroll zooms the free camera.
+func (c *camera) Scroll(amount float32) {
+	if !c.Free {
+		return
+	}
+
+	zoom := c.Zoom * float32(math.Pow(freeCameraZoomStep, float64(amount)))
+	c.Zoom = float32(math.Min(math.Max(float64(zoom), freeCameraMinZoom), freeCameraMaxZoom))
+}
+
+// updateCamera moves the camera for this frame. Following our cells, it eases
+// towards calculateCamera. The free camera is panned by the held keys.
+func (g *Game) updateCamera() {
+	c := &g.camera
+
+	now := time.Now()
+	dt := now.Sub(c.lastUpdate)
+	c.lastUpdate = now
+	if dt > time.Second {
+		dt = time.Second
+	}
+
+	if c.Free {
+		var move mgl32.Vec2
+		if c.held[engi.W] {
+			move[1]--
+		}
+		if c.held[engi.S] {
+			move[1]++
+		}
+		if c.held[engi.A] {
+			move[0]--
+		}
+		if c.held[engi.D] {
+			move[0]++
+		}
+
+		c.Center = c.Center.Add(move.Mul(freeCameraSpeed * float32(dt.Seconds()) / c.Zoom))
+		return
+	}
+
+	center, zoom, ok := g.calculateCamera()
+	if !ok {
+		if !c.placed {
+			c.Zoom = 1
+		}
+		return
+	}
+
+	if !c.placed {
+		c.Center, c.Zoom = center, zoom
+		c.placed = true
+		return
+	}
+
+	t := float32(1 - math.Exp(-dt.Seconds()/cameraSmoothTime.Seconds()))
+	c.Center = lerpVec2(c.Center, center, t)
+	c.Zoom += (zoom - c.Zoom) * t
+}
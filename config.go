@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// config holds the settings that can be loaded from a config file. Settings
+// that are left out of the file keep their defaults, and settings that also
+// have a flag are overridden by that flag when it is given.
+type config struct {
+	Gamemode   string `json:"gamemode"`
+	Region     string `json:"region"`
+	Server     string `json:"server"`
+	Key        string `json:"key"`
+	Bots       int    `json:"bots"`
+	Strategies string `json:"strategies"`
+
+	AI struct {
+		// The parameters of the AI can be reloaded while it's running.
+		aiParams
+
+		CostMapReduction int     `json:"costMapReduction"`
+		CostDoNotPass    float32 `json:"costDoNotPass"`
+	} `json:"ai"`
+
+	Bot struct {
+		SpawnRetryTime duration `json:"spawnRetryTime"`
+		Names          []string `json:"names"`
+	} `json:"bot"`
+
+	Window struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"window"`
+}
+
+// defaultConfig returns the settings that are used when there's no config file.
+func defaultConfig() *config {
+	c := new(config)
+
+	// Slices are copied, since decoding into them would modify the defaults
+	c.AI.aiParams = *defaultAIParams
+	c.AI.BorderCosts = append([]float32(nil), defaultAIParams.BorderCosts...)
+	c.AI.CostMapReduction = costMapReduction
+	c.AI.CostDoNotPass = costDoNotPass
+
+	c.Bot.SpawnRetryTime = duration(spawnRetryTime)
+	c.Bot.Names = append([]string(nil), randomNames...)
+
+	c.Window.Width = windowWidth
+	c.Window.Height = windowHeight
+
+	return c
+}
+
+// loadConfig reads the JSON config file at path on top of the defaults and
+// validates it.
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := defaultConfig()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return c, nil
+}
+
+// validate checks that every setting is usable.
+func (c *config) validate() error {
+	if c.Bots < 0 {
+		return fmt.Errorf("bots must not be negative, got %d", c.Bots)
+	}
+	if c.Strategies != "" {
+		if _, err := parseStrategies(c.Strategies); err != nil {
+			return fmt.Errorf("strategies: %s", err)
+		}
+	}
+
+	if c.AI.EatSizeRequirement <= 1 {
+		return fmt.Errorf("ai.eatSizeRequirement must be greater than 1, got %g", c.AI.EatSizeRequirement)
+	}
+	if c.AI.FoodMaxSize <= 0 {
+		return fmt.Errorf("ai.foodMaxSize must be positive, got %d", c.AI.FoodMaxSize)
+	}
+	for i, fraction := range c.AI.BorderCosts {
+		if fraction < 0 || fraction > 1 {
+			return fmt.Errorf("ai.borderCosts[%d] must be between 0 and 1, got %g", i, fraction)
+		}
+	}
+	if c.AI.PredatorPadding < 0 {
+		return fmt.Errorf("ai.predatorPadding must not be negative, got %d", c.AI.PredatorPadding)
+	}
+	if c.AI.SplitCooldown < 0 {
+		return fmt.Errorf("ai.splitCooldown must not be negative, got %s", c.AI.SplitCooldown)
+	}
+	if c.AI.CostMapReduction <= 0 {
+		return fmt.Errorf("ai.costMapReduction must be positive, got %d", c.AI.CostMapReduction)
+	}
+	if c.AI.CostDoNotPass <= 0 {
+		return fmt.Errorf("ai.costDoNotPass must be positive, got %g", c.AI.CostDoNotPass)
+	}
+
+	if c.Bot.SpawnRetryTime <= 0 {
+		return fmt.Errorf("bot.spawnRetryTime must be positive, got %s", c.Bot.SpawnRetryTime)
+	}
+	if len(c.Bot.Names) == 0 {
+		return errors.New("bot.names must not be empty")
+	}
+
+	if c.Window.Width <= 0 || c.Window.Height <= 0 {
+		return fmt.Errorf("window must have a positive size, got %dx%d", c.Window.Width, c.Window.Height)
+	}
+
+	return nil
+}
+
+// apply puts the settings into effect. Settings that have a flag are only
+// applied if that flag wasn't given on the command line.
+func (c *config) apply() {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	setFlag := func(name, value string) {
+		if value == "" || given[name] {
+			return
+		}
+		flag.Set(name, value)
+	}
+
+	setFlag("gamemode", c.Gamemode)
+	setFlag("region", c.Region)
+	setFlag("server", c.Server)
+	if !given["token"] {
+		setFlag("key", c.Key)
+	}
+	if c.Bots != 0 {
+		setFlag("bots", strconv.Itoa(c.Bots))
+	}
+	setFlag("strategies", c.Strategies)
+
+	params := c.AI.aiParams
+	storeAIParams(&params)
+	costMapReduction = c.AI.CostMapReduction
+	costDoNotPass = c.AI.CostDoNotPass
+
+	spawnRetryTime = time.Duration(c.Bot.SpawnRetryTime)
+	randomNames = c.Bot.Names
+
+	windowWidth = c.Window.Width
+	windowHeight = c.Window.Height
+}
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 2 * time.Second
+
+// watchConfig reloads the parameters of the AI from the config file at path
+// whenever the file changes or SIGHUP is received. Everything else in the
+// file only takes effect on restart. If the file is invalid, the parameters
+// in effect are kept.
+func watchConfig(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	var modTime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	for {
+		select {
+		case <-hup:
+			log.Printf("Received SIGHUP")
+		case <-ticker.C:
+			fi, err := os.Stat(path)
+			if err != nil || fi.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = fi.ModTime()
+
+			log.Printf("%s changed", path)
+		}
+
+		c, err := loadConfig(path)
+		if err != nil {
+			log.Printf("WARNING: not reloading config: %s", err)
+			continue
+		}
+
+		params := c.AI.aiParams
+		storeAIParams(&params)
+		log.Printf("Reloaded AI parameters: %+v", params)
+	}
+}
+
+// duration is a time.Duration that is written as a string, such as "300ms", in
+// the config file.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"300ms\": %s", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(parsed)
+	return nil
+}
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
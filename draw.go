@@ -24,3 +24,20 @@ func (c *circle) At(x, y int) color.Color {
 	}
 	return color.Alpha{0}
 }
+
+// tile is an opaque square.
+type tile struct {
+	size int
+}
+
+func (s *tile) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (s *tile) Bounds() image.Rectangle {
+	return image.Rect(0, 0, s.size, s.size)
+}
+
+func (s *tile) At(x, y int) color.Color {
+	return color.Alpha{255}
+}
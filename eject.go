@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// Ejected mass blobs always have a size within this range.
+	ejectedMinSize = 34
+	ejectedMaxSize = 40
+
+	// ejectedMaxMassRate is the largest change of mass per second that a
+	// cell can have and still be taken for ejected mass. Ejected mass never
+	// grows or shrinks.
+	ejectedMaxMassRate = 0.5
+
+	// ejectMinSize is the size a cell needs to be able to eject mass.
+	ejectMinSize = 59
+
+	// ejectCooldown is the time between two ejects.
+	ejectCooldown = 100 * time.Millisecond
+
+	// ejectedLaunchDistance is how far from the edge of a player a blob of
+	// ejected mass can first be seen.
+	ejectedLaunchDistance = 100
+
+	// A cell that moves at between ejectedMinPlayerSpeed and
+	// ejectedMaxPlayerSpeed times the speed of a player of its size is
+	// taken for a player, unless we saw it being ejected. Ejected mass is
+	// either launched much faster than that or lies still.
+	ejectedMinPlayerSpeed = 0.5
+	ejectedMaxPlayerSpeed = 1.2
+)
+
+// looksEjected reports whether cell has the name, size and mass of ejected
+// mass. Small unnamed players look the same.
+func (ai *AI) looksEjected(cell *agario.Cell) bool {
+	if cell.Name != "" || cell.Size < ejectedMinSize || cell.Size > ejectedMaxSize {
+		return false
+	}
+
+	if tc := ai.tracker.Get(cell.ID); tc != nil && math.Abs(float64(tc.MassRate)) > ejectedMaxMassRate {
+		return false
+	}
+
+	return true
+}
+
+// updateLaunchedMass keeps track of the cells that we saw being ejected. A cell
+// that looks like ejected mass and first appears next to a player that is
+// able to eject is remembered along with that player. Once we know its
+// velocity, it's confirmed as ejected if it's moving away from that player.
+func (ai *AI) updateLaunchedMass() {
+	if ai.launchers == nil {
+		ai.launchers = make(map[uint32]uint32)
+		ai.launched = make(map[uint32]struct{})
+	}
+
+	for id := range ai.launchers {
+		if _, ok := ai.g.Cells[id]; !ok {
+			delete(ai.launchers, id)
+		}
+	}
+	for id := range ai.launched {
+		if _, ok := ai.g.Cells[id]; !ok {
+			delete(ai.launched, id)
+		}
+	}
+
+	for _, cell := range ai.cells {
+		id := cell.ID
+		if _, ok := ai.launched[id]; ok || cell.IsVirus || !ai.looksEjected(cell) {
+			continue
+		}
+
+		tc := ai.tracker.Get(id)
+		if tc == nil {
+			continue
+		}
+
+		if tc.FirstSeen == ai.clock {
+			if launcher := ai.findLauncher(cell); launcher != nil {
+				ai.launchers[id] = launcher.ID
+			}
+			continue
+		}
+
+		launcherID, ok := ai.launchers[id]
+		if !ok || len(tc.History) < 2 {
+			continue
+		}
+		delete(ai.launchers, id)
+
+		launcher, ok := ai.g.Cells[launcherID]
+		if ok && tc.Velocity.Dot(cell.Position.Sub(launcher.Position)) > 0 {
+			ai.launched[id] = struct{}{}
+		}
+	}
+}
+
+// findLauncher returns a cell that is large enough to eject and close enough
+// to have ejected cell, or nil if there is none.
+func (ai *AI) findLauncher(cell *agario.Cell) *agario.Cell {
+	for _, other := range ai.cells {
+		if other == cell || other.IsVirus || other.Size < ejectMinSize {
+			continue
+		}
+
+		if dist2(cell.Position, other.Position) <= square(float32(other.Size)+ejectedLaunchDistance) {
+			return other
+		}
+	}
+	return nil
+}
+
+// movesLikePlayer reports whether cell is moving at about the speed that a
+// player of its size moves at.
+func (ai *AI) movesLikePlayer(cell *agario.Cell) bool {
+	playerSpeed := cell.Speed() * serverTicksPerSecond
+	speed := ai.tracker.Velocity(cell.ID).Len()
+
+	return speed >= playerSpeed*ejectedMinPlayerSpeed && speed <= playerSpeed*ejectedMaxPlayerSpeed
+}
+
+// isEjectedMass reports whether cell is a blob of ejected mass rather than a
+// player. It must look like ejected mass, and either have been seen being
+// ejected or not move like a player.
+func (ai *AI) isEjectedMass(cell *agario.Cell) bool {
+	if !ai.looksEjected(cell) {
+		return false
+	}
+
+	if _, ok := ai.launched[cell.ID]; ok {
+		return true
+	}
+
+	return !ai.movesLikePlayer(cell)
+}
+
+// canEject reports whether any of our cells is large enough to eject mass.
+func (ai *AI) canEject() bool {
+	return ai.getLargestOwnCell().Size >= ejectMinSize
+}
+
+// ejectTowards aims at target and ejects mass towards it, as often as the
+// eject cooldown allows. It reports whether mass was ejected.
+func (ai *AI) ejectTowards(target mgl32.Vec2) bool {
+	ai.Path = []mgl32.Vec2{ai.Me.Position, target}
+	ai.c.SetTargetPos(target.X(), target.Y())
+
+	if ai.timeToNextEject > 0 || !ai.canEject() {
+		return false
+	}
+
+	ai.c.EjectMass()
+	ai.timeToNextEject = ejectCooldown
+	return true
+}
+
+// scavenge attempts to eat the nearest blob of ejected mass
+func (ai *AI) scavenge() (float64, Action) {
+	edibleSize := int32(float32(ai.SmallestOwnCell.Size) / ai.params.EatSizeRequirement)
+
+	closestEjected := ai.getClosestFiltered(ai.Me.Position, ai.Ejected, func(cell *agario.Cell) bool {
+		return cell.Size <= edibleSize
+	})
+	if closestEjected == nil {
+		ai.addStatusMessage("Not scavenging: No edible ejected mass")
+		return 0, nil
+	}
+
+	return priorityNormal, func() {
+		ai.addStatusMessage("Eating ejected mass")
+		ai.movePathed(closestEjected.Position)
+		ai.State = stateFeeding
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// serverTicksPerSecond is how many times per second the server moves
+	// cells. Cell.Speed is the distance a cell moves in one of these ticks.
+	serverTicksPerSecond = 25
+
+	// fleeHorizon is how far ahead flee predicts the movement of predators.
+	fleeHorizon = 1500 * time.Millisecond
+	// fleeSteps is the number of points along the horizon that are checked
+	// for intercepts.
+	fleeSteps = 10
+	// fleeHeadings is the number of escape headings that are considered.
+	fleeHeadings = 32
+	// fleeDistance is how far ahead of us the escape target is placed.
+	fleeDistance = 500
+	// fleePadding is added to the distance at which a predator can eat us.
+	fleePadding = 100
+)
+
+// predatorThreat is a predator that can eat one of our cells, along with our
+// prediction of its movement.
+type predatorThreat struct {
+	Cell *agario.Cell
+	// Target is the own cell that Cell is able to eat.
+	Target *agario.Cell
+
+	Velocity mgl32.Vec2
+
+	// Radius is the distance from the predator at which Target can be eaten.
+	Radius float32
+}
+
+func (t *predatorThreat) positionAt(seconds float32) mgl32.Vec2 {
+	return t.Cell.Position.Add(t.Velocity.Mul(seconds))
+}
+
+// threatRadius returns the distance from cell at which it can eat target,
+// either by moving over it or by splitting onto it.
+func (ai *AI) threatRadius(cell, target *agario.Cell) float32 {
+	radius := float32(cell.Size) - float32(target.Size)*0.35 + fleePadding
+
+	canBeSplitKilledBySize := int32((float64(target.Size)*float64(ai.params.EatSizeRequirement) - 10) * 2)
+	ignoreSplitKillSize := target.Size * 4 // If they're 4x larger than us, they're unlikely to split to kill us
+	if cell.Size >= canBeSplitKilledBySize && cell.Size < ignoreSplitKillSize {
+		if splitRadius := cell.SplitDistance() + fleePadding; splitRadius > radius {
+			radius = splitRadius
+		}
+	}
+
+	return radius
+}
+
+// getThreats returns, for each of our cells, every predator that could reach
+// it within fleeHorizon if it keeps moving the way it has been.
+func (ai *AI) getThreats() []*predatorThreat {
+	horizon := float32(fleeHorizon.Seconds())
+
+	var threats []*predatorThreat
+	for _, own := range ai.OwnCells {
+		predatorSize := int32(float32(own.Size)*ai.params.EatSizeRequirement) - 1
+
+		for _, cell := range ai.Predators {
+			if cell.Size < predatorSize {
+				continue
+			}
+
+			t := &predatorThreat{
+				Cell:   cell,
+				Target: own,
+
+				Velocity: ai.tracker.Velocity(cell.ID),
+				Radius:   ai.threatRadius(cell, own),
+			}
+
+			if closestApproach(own.Position, t, horizon) <= t.Radius {
+				threats = append(threats, t)
+			}
+		}
+	}
+
+	return threats
+}
+
+// closestApproach returns the smallest distance between p and the predicted
+// position of t within the next horizon seconds.
+func closestApproach(p mgl32.Vec2, t *predatorThreat, horizon float32) float32 {
+	rel := t.Cell.Position.Sub(p)
+
+	var when float32
+	if speed2 := t.Velocity.Dot(t.Velocity); speed2 > 0 {
+		when = -rel.Dot(t.Velocity) / speed2
+		if when < 0 {
+			when = 0
+		} else if when > horizon {
+			when = horizon
+		}
+	}
+
+	return rel.Add(t.Velocity.Mul(when)).Len()
+}
+
+// clearance simulates our cells moving according to position and returns the
+// smallest margin by which every cell stays out of reach of the threats to
+// it. A negative clearance means that one of our cells is predicted to be
+// intercepted.
+func (ai *AI) clearance(threats []*predatorThreat, position func(own *agario.Cell, seconds float32) mgl32.Vec2) float32 {
+	horizon := float32(fleeHorizon.Seconds())
+	clearance := float32(math.MaxFloat32)
+
+	for step := 1; step <= fleeSteps; step++ {
+		seconds := horizon * float32(step) / fleeSteps
+
+		for _, t := range threats {
+			pos := position(t.Target, seconds)
+			if c := t.positionAt(seconds).Sub(pos).Len() - t.Radius; c < clearance {
+				clearance = c
+			}
+		}
+	}
+
+	return clearance
+}
+
+// groupClearance returns the clearance of our cells if they all move towards
+// cursor.
+func (ai *AI) groupClearance(cursor mgl32.Vec2, threats []*predatorThreat) float32 {
+	return ai.clearance(threats, func(own *agario.Cell, seconds float32) mgl32.Vec2 {
+		return ai.predictOwnPosition(own, cursor, seconds)
+	})
+}
+
+// stationaryClearance returns the clearance of our cells if they stay where
+// they are.
+func (ai *AI) stationaryClearance(threats []*predatorThreat) float32 {
+	return ai.clearance(threats, func(own *agario.Cell, _ float32) mgl32.Vec2 {
+		return own.Position
+	})
+}
+
+func (ai *AI) clampToBoard(p mgl32.Vec2) mgl32.Vec2 {
+	return mgl32.Vec2{
+		mgl32.Clamp(p.X(), float32(ai.g.Board.Left), float32(ai.g.Board.Right)),
+		mgl32.Clamp(p.Y(), float32(ai.g.Board.Top), float32(ai.g.Board.Bottom)),
+	}
+}
+
+// flee picks the heading that keeps all of our cells furthest from every
+// predicted predator intercept and moves along it
+func (ai *AI) flee() (float64, Action) {
+	if len(ai.Predators) == 0 {
+		ai.addStatusMessage("Not fleeing: No known predators")
+		return 0, nil
+	}
+
+	threats := ai.Threats
+	if len(threats) == 0 {
+		ai.addStatusMessage("Not fleeing: No dangerous predators nearby")
+		return 0, nil
+	}
+
+	// away is used to break ties between headings that are equally safe
+	var (
+		away             mgl32.Vec2
+		nearest          *predatorThreat
+		nearestClearance float32
+	)
+	for _, t := range threats {
+		delta := t.Target.Position.Sub(t.Cell.Position)
+		if delta.Len() > 0 {
+			away = away.Add(delta.Normalize())
+		}
+
+		if c := delta.Len() - t.Radius; nearest == nil || c < nearestClearance {
+			nearest = t
+			nearestClearance = c
+		}
+	}
+
+	var (
+		bestTarget    mgl32.Vec2
+		bestClearance = float32(-math.MaxFloat32)
+	)
+	for i := 0; i < fleeHeadings; i++ {
+		angle := 2 * math.Pi * float64(i) / fleeHeadings
+		heading := mgl32.Vec2{float32(math.Cos(angle)), float32(math.Sin(angle))}
+		target := ai.clampToBoard(ai.Me.Position.Add(heading.Mul(fleeDistance)))
+
+		clearance := ai.groupClearance(target, threats) + heading.Dot(away)
+		if clearance > bestClearance {
+			bestTarget = target
+			bestClearance = clearance
+		}
+	}
+
+	priority := float64(priorityNormal)
+	if ai.stationaryClearance(threats) < 0 {
+		// Staying where we are gets us eaten
+		priority = priorityUrgent
+	}
+
+	return priority, func() {
+		ai.addStatusMessage("Fleeing from " + prettyCellName(nearest.Cell))
+		ai.movePathed(bestTarget)
+		ai.State = stateFleeing
+	}
+}
@@ -1,25 +1,43 @@
 package main
 
 import (
+	"log"
+	"math"
 	"sort"
 
 	"github.com/ajhager/engi"
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/nightexcessive/agario"
 )
 
 type Game struct {
 	*engi.Game
 
-	g        *agario.Game
-	quitChan chan struct{}
+	g    *agario.Game
+	ai   *AI
+	quit func()
+
+	// bots are every bot that we can follow. following is the index of the
+	// one being drawn.
+	bots      []*bot
+	following int
 
 	batch *engi.Batch
 	W, H  float32
 
-	cameraX, cameraY float32
+	camera camera
+
+	circle            engi.Drawable
+	tile              engi.Drawable
+	minimapBackground engi.Drawable
+	font              *engi.Font
+	glyphs            []*engi.Region
+
+	heatmap heatmapMode
 
-	circle engi.Drawable
-	font   *engi.Font
+	// sightings are the large players seen in sightingsOf, for the minimap
+	sightings   map[uint32]*sighting
+	sightingsOf *agario.Game
 }
 
 func (g *Game) Preload() {
@@ -30,28 +48,66 @@ func (g *Game) Preload() {
 
 const (
 	circleSize = 2048
+	tileSize   = 16
 )
 
 func (g *Game) Setup() {
 	engi.SetBg(0x2d3739)
-	g.font = engi.NewGridFont(engi.Files.Image("font"), 20, 20)
-	g.circle = g.getCircleTexture(circleSize / 2)
+	g.font = engi.NewGridFont(engi.Files.Image("font"), glyphSize, glyphSize)
+	g.glyphs = newGlyphs(engi.Files.Image("font"))
+	circle := g.getCircleTexture(circleSize / 2)
+	g.circle = circle
+	g.minimapBackground = newMinimapBackground(circle)
+	g.tile = engi.NewTexture(engi.LoadImage(&tile{tileSize}))
 }
 
 func (g *Game) Render() {
+	g.g, g.ai = g.bots[g.following].Current()
+	if g.g == nil {
+		return
+	}
+
 	g.g.Lock()
 	defer g.g.Unlock()
 
 	g.batch.Begin()
 
-	g.cameraX, g.cameraY = g.calculateCamera()
+	g.updateCamera()
+	g.renderHeatmap()
 	g.renderCells()
+	g.renderPath()
+	g.renderMinimap()
+	g.renderStatus()
 
 	g.batch.End()
 }
 
 func (g *Game) Close() {
-	close(g.quitChan)
+	g.quit()
+}
+
+func (g *Game) Key(key engi.Key, modifier engi.Modifier, action engi.Action) {
+	g.camera.Key(key, action)
+
+	if action != engi.PRESS {
+		return
+	}
+
+	switch key {
+	case engi.H:
+		g.heatmap = (g.heatmap + 1) % heatmapModes
+		log.Printf("Heatmap: %s", heatmapNames[g.heatmap])
+	case engi.N:
+		// Follow the next bot
+		g.following = (g.following + 1) % len(g.bots)
+		if name := g.bots[g.following].Name; name != "" {
+			log.Printf("Following %s", name)
+		}
+	}
+}
+
+func (g *Game) Scroll(amount float32) {
+	g.camera.Scroll(amount)
 }
 
 func (g *Game) Resize(w, h int) {
@@ -61,17 +117,35 @@ func (g *Game) Resize(w, h int) {
 
 func (g *Game) renderCells() {
 	cells := g.getCells()
+	highlights := g.getHighlights()
 
 	for _, c := range cells {
+		if col, ok := highlights[c]; ok {
+			g.drawHighlight(c, col)
+		}
 		g.drawCell(c)
+		g.drawLabels(c)
 	}
 }
 
 func (g *Game) drawCell(c *agario.Cell) {
 	red, green, blue, alpha := c.Color.RGBA()
 	colVal := ((red & 0xFF) << 16) | ((green & 0xFF) << 8) | (blue & 0xFF)
-	scale := float32(c.Size) / circleSize
-	g.batch.Draw(g.circle, c.Position.X()-g.cameraX, c.Position.Y()-g.cameraY, 0.5, 0.5, scale, scale, 0, colVal, float32(alpha)/255)
+	scale := float32(c.Size) / circleSize * g.camera.Zoom
+	x, y := g.worldToScreen(c.Position)
+	g.batch.Draw(g.circle, x, y, 0.5, 0.5, scale, scale, 0, colVal, float32(alpha)/255)
+}
+
+// worldToScreen returns where a position in the game is drawn on the screen.
+func (g *Game) worldToScreen(p mgl32.Vec2) (float32, float32) {
+	s := p.Sub(g.camera.Center).Mul(g.camera.Zoom)
+	return s.X() + g.W/2, s.Y() + g.H/2
+}
+
+// screenToWorld returns the position in the game that is drawn at x, y on the
+// screen.
+func (g *Game) screenToWorld(x, y float32) mgl32.Vec2 {
+	return mgl32.Vec2{x - g.W/2, y - g.H/2}.Mul(1 / g.camera.Zoom).Add(g.camera.Center)
 }
 
 func (g *Game) getCells() []*agario.Cell {
@@ -85,29 +159,33 @@ func (g *Game) getCells() []*agario.Cell {
 	return cells
 }
 
-func (g *Game) calculateCamera() (float32, float32) {
-	var center *agario.Cell
+// calculateCamera returns where the camera should be centred and how far it
+// should be zoomed to follow our cells. It centres on the mass-weighted
+// centroid of our cells and zooms out as they grow, the same way that the
+// real client does. ok is false if we have no cells.
+func (g *Game) calculateCamera() (center mgl32.Vec2, zoom float32, ok bool) {
+	var totalMass, totalSize float32
 	for id := range g.g.MyIDs {
 		cell, ok := g.g.Cells[id]
 		if !ok {
 			continue
 		}
 
-		if center == nil || cell.ID < center.ID {
-			center = cell
-		}
+		mass := sizeToMass(cell.Size)
+		center = center.Add(cell.Position.Mul(mass))
+		totalMass += mass
+		totalSize += float32(cell.Size)
 	}
 
-	if center == nil {
-		return 0, 0
+	if totalMass == 0 {
+		return mgl32.Vec2{}, 1, false
 	}
 
-	x, y := center.Position.Elem()
-
-	return x - g.W/2, y - g.H/2
+	zoom = float32(math.Pow(math.Min(64/float64(totalSize), 1), 0.4))
+	return center.Mul(1 / totalMass), zoom, true
 }
 
-func (g *Game) getCircleTexture(d int) engi.Drawable {
+func (g *Game) getCircleTexture(d int) *engi.Texture {
 	img := engi.LoadImage(&circle{d})
 	t := engi.NewTexture(img)
 	return t
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// heatmapMode selects what the heatmap overlay shows.
+type heatmapMode int
+
+const (
+	heatmapOff heatmapMode = iota
+	// heatmapCost shows the cost of every cell of the cost map.
+	heatmapCost
+	// heatmapDistance shows the Dijkstra distance from our cell to every cell
+	// of the cost map.
+	heatmapDistance
+
+	heatmapModes
+)
+
+var heatmapNames = map[heatmapMode]string{
+	heatmapOff:      "off",
+	heatmapCost:     "cost map",
+	heatmapDistance: "distance",
+}
+
+const heatmapAlpha = 0.35
+
+// renderHeatmap draws the cost map or Dijkstra distances of the AI as
+// translucent tiles, going from green for the cheapest to red for the most
+// expensive.
+func (g *Game) renderHeatmap() {
+	if g.heatmap == heatmapOff || g.ai == nil || g.ai.Map == nil {
+		return
+	}
+
+	m := g.ai.Map
+
+	// Only the tiles that are on the screen are drawn
+	topLeft, bottomRight := g.screenToWorld(0, 0), g.screenToWorld(g.W, g.H)
+	minX, minY := gameToCostMap(topLeft.Elem())
+	maxX, maxY := gameToCostMap(bottomRight.Elem())
+	minX, minY = clampInt(minX, 0, len(m)-1), clampInt(minY, 0, len(m[0])-1)
+	maxX, maxY = clampInt(maxX, 0, len(m)-1), clampInt(maxY, 0, len(m[0])-1)
+
+	values := make([][]float64, maxX-minX+1)
+	var max float64
+	for x := minX; x <= maxX; x++ {
+		column := make([]float64, maxY-minY+1)
+		for y := minY; y <= maxY; y++ {
+			var v float64
+			switch g.heatmap {
+			case heatmapCost:
+				v = float64(m[x][y])
+			case heatmapDistance:
+				v = g.ai.DijkstraMap.WeightTo(m.GetNode(x, y))
+			}
+
+			column[y-minY] = v
+			if !math.IsInf(v, 0) && v > max {
+				max = v
+			}
+		}
+		values[x-minX] = column
+	}
+
+	if g.heatmap == heatmapCost {
+		max = float64(costDoNotPass)
+	}
+	if max == 0 {
+		return
+	}
+
+	scale := float32(costMapReduction) / tileSize * g.camera.Zoom
+	for i, column := range values {
+		for j, v := range column {
+			if g.heatmap == heatmapCost && v == 0 {
+				continue
+			}
+
+			// Nodes that can't be reached are infinitely far away, so
+			// this clamps them to 1
+			t := math.Min(v/max, 1)
+			gameX, gameY := costMapToGame(minX+i, minY+j)
+			x, y := g.worldToScreen(mgl32.Vec2{gameX, gameY})
+			g.batch.Draw(g.tile, x, y, 0, 0, scale, scale, 0, heatColor(t), heatmapAlpha)
+		}
+	}
+}
+
+// heatColor returns the colour for t, which goes from 0 for green to 1 for
+// red. NaN is drawn in red, the same as the highest value.
+func heatColor(t float64) uint32 {
+	if math.IsNaN(t) {
+		t = 1
+	}
+
+	red := uint32(255 * t)
+	green := uint32(255 * (1 - t))
+	return red<<16 | green<<8
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
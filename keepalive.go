@@ -1,15 +1,21 @@
 package main
 
 import (
-	"log"
 	"time"
 
 	"github.com/nightexcessive/agario"
 )
 
+// spawnRetryTime is how long keepAlive waits after the first failed attempt to
+// spawn. Every further attempt waits a little longer. It can be set by the
+// config file.
+var spawnRetryTime = 300 * time.Millisecond
+
 // keepAlive keeps the AI alive. If the AI dies, it tries to respawn the AI.
 type keepAlive struct {
-	g *agario.Game
+	g   *agario.Game
+	c   commander
+	log botLogger
 
 	tryNum  int
 	nextTry time.Time
@@ -20,7 +26,7 @@ type keepAlive struct {
 func (k *keepAlive) Update(_ time.Duration) {
 	if k.currentlyAlive() {
 		if k.tryNum != 0 {
-			log.Printf("Spawned as \"%s\"", k.currentNickname)
+			k.log.Printf("Spawned as \"%s\"", k.currentNickname)
 
 			k.tryNum = 0
 			k.currentNickname = ""
@@ -35,14 +41,9 @@ func (k *keepAlive) Update(_ time.Duration) {
 		return
 	}
 
-	const (
-		retryTime  = 300 * time.Millisecond
-		randomness = retryTime / 10
-	)
-
 	k.trySpawn()
 	k.tryNum++
-	k.nextTry = now.Add(retryTime * time.Duration(k.tryNum))
+	k.nextTry = now.Add(spawnRetryTime * time.Duration(k.tryNum))
 }
 
 func (k *keepAlive) trySpawn() {
@@ -50,8 +51,8 @@ func (k *keepAlive) trySpawn() {
 		k.currentNickname = randomName()
 	}
 
-	log.Printf("Trying to spawn as \"%s\"", k.currentNickname)
-	k.g.SendNickname(k.currentNickname)
+	k.log.Printf("Trying to spawn as \"%s\"", k.currentNickname)
+	k.c.SendNickname(k.currentNickname)
 }
 
 func (k *keepAlive) currentlyAlive() bool {
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/ajhager/engi"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// The font is a grid of 20x20 glyphs, in order of their runes.
+	glyphSize    = 20
+	labelColor   = 0xffffff
+	labelMinSize = 40
+
+	// nameHeight and massHeight are the heights of the labels relative to
+	// the size of the cell. A label is never wider than labelMaxWidth
+	// relative to the size of the cell.
+	nameHeight    = 0.25
+	massHeight    = 0.15
+	labelMaxWidth = 0.9
+)
+
+// newGlyphs cuts every glyph out of the font texture, the same way that
+// engi.NewGridFont does. engi.Font keeps its glyphs to itself and only draws
+// them at their own size, so labels can't be drawn with it.
+func newGlyphs(t *engi.Texture) []*engi.Region {
+	columns := int(t.Width()) / glyphSize
+	rows := int(t.Height()) / glyphSize
+
+	glyphs := make([]*engi.Region, columns*rows)
+	for i := range glyphs {
+		x, y := (i%columns)*glyphSize, (i/columns)*glyphSize
+		glyphs[i] = engi.NewRegion(t, x, y, glyphSize, glyphSize)
+	}
+	return glyphs
+}
+
+// drawLabels writes the name and mass of c over it. Cells that are drawn too
+// small to read are skipped.
+func (g *Game) drawLabels(c *agario.Cell) {
+	x, y := g.worldToScreen(c.Position)
+	size := float32(c.Size) * g.camera.Zoom
+	if size < labelMinSize {
+		return
+	}
+
+	mass := strconv.Itoa(int(sizeToMass(c.Size)))
+	massH := labelHeight(mass, size, massHeight)
+	if c.Name == "" {
+		g.drawText(mass, x, y, massH)
+		return
+	}
+
+	nameH := labelHeight(c.Name, size, nameHeight)
+	g.drawText(c.Name, x, y-massH/2, nameH)
+	g.drawText(mass, x, y+nameH/2, massH)
+}
+
+// labelHeight returns the height of the glyphs of a label on a cell of the
+// given size, shrunk from height if the label would be too wide.
+func labelHeight(text string, size, height float32) float32 {
+	h := size * height
+	if n := float32(len([]rune(text))); n*h > size*labelMaxWidth {
+		h = size * labelMaxWidth / n
+	}
+	return h
+}
+
+// drawText draws text centred on x, y with glyphs of the given height.
+func (g *Game) drawText(text string, x, y, height float32) {
+	runes := []rune(text)
+	scale := height / glyphSize
+
+	left := x - float32(len(runes))*height/2
+	top := y - height/2
+	for i, r := range runes {
+		if r < 0 || int(r) >= len(g.glyphs) {
+			continue
+		}
+		g.batch.Draw(g.glyphs[r], left+float32(i)*height, top, 0, 0, scale, scale, 0, labelColor, 1)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/gorilla/websocket"
+)
+
+// Opcodes of the agar.io protocol spoken by the local server.
+const (
+	opNickname    = 0
+	opSpectate    = 1
+	opTargetPos   = 16
+	opSplit       = 17
+	opEject       = 21
+	opHandshake1  = 254
+	opHandshake2  = 255
+	opUpdate      = 16
+	opClear       = 20
+	opAddNode     = 32
+	opLeaderboard = 49
+	opBorder      = 64
+)
+
+const (
+	localTickTime        = time.Second / serverTicksPerSecond
+	localLeaderboardTime = 500 * time.Millisecond
+	localLeaderboardSize = 10
+)
+
+// localServer is a stand-in for an agar.io server. It runs a world and speaks
+// the same websocket protocol as the real servers, so that the bot can be run
+// without network access.
+type localServer struct {
+	mu      sync.Mutex
+	world   *world
+	clients map[*localClient]struct{}
+
+	upgrader websocket.Upgrader
+}
+
+// localClient is a single connection to the local server.
+type localClient struct {
+	conn   *websocket.Conn
+	player *worldPlayer
+
+	// known holds the cells that the client has been told about. own holds
+	// the cells that the client has been told are its own.
+	known map[uint32]struct{}
+	own   map[uint32]struct{}
+	// center is where the client's view was last centered.
+	center mgl32.Vec2
+}
+
+func newLocalServer(rng *rand.Rand, npcs int) *localServer {
+	s := &localServer{
+		world:   newWorld(rng),
+		clients: make(map[*localClient]struct{}),
+
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	}
+
+	for i := 0; i < npcs; i++ {
+		s.world.AddNPC("NPC " + strconv.Itoa(i+1))
+	}
+
+	return s
+}
+
+// Serve runs the simulation and accepts connections on l.
+func (s *localServer) Serve(l net.Listener) error {
+	go s.run()
+
+	log.Printf("Local server listening on %s", l.Addr())
+	return http.Serve(l, s)
+}
+
+func (s *localServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Local server: upgrade failed: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	c := &localClient{
+		conn:   conn,
+		player: s.world.AddPlayer(),
+
+		known:  make(map[uint32]struct{}),
+		own:    make(map[uint32]struct{}),
+		center: mgl32.Vec2{s.world.Width / 2, s.world.Height / 2},
+	}
+	border := borderPacket(s.world)
+	s.mu.Unlock()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, border); err != nil {
+		s.mu.Lock()
+		s.world.RemovePlayer(c.player)
+		s.mu.Unlock()
+
+		conn.Close()
+		return
+	}
+
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	log.Printf("Local server: %s connected", r.RemoteAddr)
+	s.readLoop(c)
+	log.Printf("Local server: %s disconnected", r.RemoteAddr)
+
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.world.RemovePlayer(c.player)
+	s.mu.Unlock()
+
+	conn.Close()
+}
+
+// readLoop handles the messages sent by a client until it disconnects.
+func (s *localServer) readLoop(c *localClient) {
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(msg) == 0 {
+			continue
+		}
+
+		s.mu.Lock()
+		s.handleMessage(c, msg[0], msg[1:])
+		s.mu.Unlock()
+	}
+}
+
+func (s *localServer) handleMessage(c *localClient, op byte, payload []byte) {
+	switch op {
+	case opNickname:
+		s.world.Spawn(c.player, decodeUTF16(payload))
+	case opTargetPos:
+		// Older clients send 32 bit integer coordinates, newer ones doubles
+		switch len(payload) {
+		case 20:
+			x := math.Float64frombits(binary.LittleEndian.Uint64(payload[0:]))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(payload[8:]))
+			c.player.Target = mgl32.Vec2{float32(x), float32(y)}
+		case 12:
+			x := int32(binary.LittleEndian.Uint32(payload[0:]))
+			y := int32(binary.LittleEndian.Uint32(payload[4:]))
+			c.player.Target = mgl32.Vec2{float32(x), float32(y)}
+		}
+	case opSplit:
+		c.player.Split()
+	case opEject:
+		c.player.Eject()
+	case opSpectate, opHandshake1, opHandshake2:
+		// Nothing to do
+	}
+}
+
+// run steps the world and sends updates to every client.
+func (s *localServer) run() {
+	ticker := time.NewTicker(localTickTime)
+	defer ticker.Stop()
+
+	var sinceLeaderboard time.Duration
+	for range ticker.C {
+		s.mu.Lock()
+
+		s.world.Step(localTickTime)
+
+		sinceLeaderboard += localTickTime
+		var leaderboard []byte
+		if sinceLeaderboard >= localLeaderboardTime {
+			sinceLeaderboard = 0
+			leaderboard = leaderboardPacket(s.world.Leaderboard(localLeaderboardSize))
+		}
+
+		packets := make(map[*localClient][][]byte, len(s.clients))
+		for c := range s.clients {
+			packets[c] = s.clientPackets(c, leaderboard)
+		}
+		s.world.Eaten = s.world.Eaten[:0]
+
+		s.mu.Unlock()
+
+		for c, msgs := range packets {
+			for _, msg := range msgs {
+				c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+				if err := c.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+					// The read loop notices the closed connection and cleans up
+					c.conn.Close()
+					break
+				}
+			}
+		}
+	}
+}
+
+// clientPackets builds every packet that a client needs this tick.
+func (s *localServer) clientPackets(c *localClient, leaderboard []byte) [][]byte {
+	var packets [][]byte
+
+	if !c.player.Alive() && len(c.own) > 0 {
+		// The client died. Start over with a clean slate.
+		c.known = make(map[uint32]struct{})
+		c.own = make(map[uint32]struct{})
+		packets = append(packets, []byte{opClear})
+	}
+
+	for _, cell := range c.player.Cells {
+		if _, ok := c.own[cell.ID]; ok {
+			continue
+		}
+		c.own[cell.ID] = struct{}{}
+
+		p := []byte{opAddNode, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(p[1:], cell.ID)
+		packets = append(packets, p)
+	}
+	for id := range c.own {
+		if _, ok := s.world.Cells[id]; !ok {
+			delete(c.own, id)
+		}
+	}
+
+	packets = append(packets, s.updatePacket(c))
+	if leaderboard != nil {
+		packets = append(packets, leaderboard)
+	}
+
+	return packets
+}
+
+// updatePacket builds the world update for a client: the cells that were
+// eaten, every cell in view, and the cells that left the view.
+func (s *localServer) updatePacket(c *localClient) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(opUpdate)
+
+	binary.Write(&buf, binary.LittleEndian, uint16(len(s.world.Eaten)))
+	for _, e := range s.world.Eaten {
+		binary.Write(&buf, binary.LittleEndian, e.Eater)
+		binary.Write(&buf, binary.LittleEndian, e.Victim)
+	}
+
+	// Dead players keep seeing where they died
+	center, halfW, halfH, alive := c.player.View()
+	if alive {
+		c.center = center
+	} else {
+		halfW, halfH = viewHalfWidth, viewHalfHeight
+	}
+
+	visible := make(map[uint32]struct{})
+	for _, cell := range s.world.sortedCells() {
+		if !cell.inView(c.center, halfW, halfH) {
+			continue
+		}
+		visible[cell.ID] = struct{}{}
+
+		var flags uint8
+		if cell.Kind == kindVirus {
+			flags |= 1
+		}
+		name := ""
+		if cell.Owner != nil {
+			name = cell.Owner.Name
+		}
+
+		binary.Write(&buf, binary.LittleEndian, cell.ID)
+		binary.Write(&buf, binary.LittleEndian, int32(cell.Position.X()))
+		binary.Write(&buf, binary.LittleEndian, int32(cell.Position.Y()))
+		binary.Write(&buf, binary.LittleEndian, int16(cell.Size()))
+		buf.Write([]byte{cell.Color.R, cell.Color.G, cell.Color.B, flags})
+		buf.Write(encodeUTF16(name))
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+
+	var removed []uint32
+	for id := range c.known {
+		if _, ok := visible[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	c.known = visible
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(removed)))
+	for _, id := range removed {
+		binary.Write(&buf, binary.LittleEndian, id)
+	}
+
+	return buf.Bytes()
+}
+
+func borderPacket(w *world) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(opBorder)
+	binary.Write(&buf, binary.LittleEndian, [4]float64{0, 0, float64(w.Width), float64(w.Height)})
+	return buf.Bytes()
+}
+
+func leaderboardPacket(players []*worldPlayer) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(opLeaderboard)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(players)))
+	for _, p := range players {
+		binary.Write(&buf, binary.LittleEndian, p.Cells[0].ID)
+		buf.Write(encodeUTF16(p.Name))
+	}
+	return buf.Bytes()
+}
+
+// encodeUTF16 encodes s as a null terminated little endian UTF-16 string.
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units)+2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// decodeUTF16 decodes a little endian UTF-16 string, stopping at the first
+// null character.
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u := binary.LittleEndian.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"os"
+	"os/signal"
 	"runtime/pprof"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ajhager/engi"
@@ -17,59 +23,204 @@ const (
 	frameTime       = time.Second / framesPerSecond
 )
 
-func handleGameEvents(c chan struct{}, g *agario.Game) {
+// handleGameEvents processes the messages of g and signals c after each batch
+// of them. It returns once the connection is lost or stop is closed. A
+// blocking RunOnce that doesn't process a message means that the connection
+// has been lost.
+func handleGameEvents(c chan<- struct{}, stop <-chan struct{}, g *agario.Game) {
 	for {
-		g.RunOnce(false)
+		if !g.RunOnce(false) {
+			return
+		}
 		for g.RunOnce(true) {
 		}
-		c <- struct{}{}
+
+		select {
+		case c <- struct{}{}:
+		case <-stop:
+			return
+		}
 	}
 }
 
-func run(ig *agario.Game) {
-	gameEvents := make(chan struct{})
-	quitChan := make(chan struct{})
+// The size of the window. It can be set by the config file.
+var (
+	windowWidth  = 1280
+	windowHeight = 800
+)
+
+// stopOnSignal calls quit when we receive SIGINT or SIGTERM. Only the first
+// signal is caught, so that a second one kills us if stopping hangs. It
+// returns a function that stops watching for them.
+func stopOnSignal(quit func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			log.Printf("Received %s. Stopping... (send it again to kill)", sig)
+			quit()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// run drives the renderer and every bot until the window is closed or quitChan
+// is closed, then logs a summary of every bot's session. quit closes quitChan.
+// With -headless there's no window, so only quit stops it.
+func run(bots []*bot, quitChan <-chan struct{}, quit func()) {
+	if !*headless {
+		closed := make(chan struct{})
+		var closeOnce sync.Once
+		g := &Game{
+			quit: func() {
+				closeOnce.Do(func() {
+					close(closed)
+				})
+				quit()
+			},
+			bots: bots,
+		}
+		go engi.Open("agariobot", windowWidth, windowHeight, false, g)
 
-	g := &Game{
-		g:        ig,
-		quitChan: quitChan,
+		go func() {
+			select {
+			case <-quitChan:
+				// We were told to stop some other way, so close the window
+				select {
+				case <-closed:
+				default:
+					engi.Exit()
+				}
+			case <-closed:
+			}
+		}()
 	}
-	/*ai := &AI{
-		g: ig,
-	}*/
-	ka := &keepAlive{
-		g: ig,
+
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func(b *bot) {
+			defer wg.Done()
+			b.Run(quitChan)
+		}(b)
 	}
 
-	go handleGameEvents(gameEvents, ig)
-	go engi.Open("agariobot", 1280, 800, false, g)
+	wg.Wait()
 
-	lastTick := time.Now()
+	select {
+	case <-quitChan:
+	default:
+		if !*headless {
+			log.Printf("Every bot has stopped. Waiting for the window to close.")
+			<-quitChan
+		}
+	}
 
-mainLoop:
-	for {
-		select {
-		case _, ok := <-quitChan:
-			if !ok {
-				break mainLoop
-			}
-		case <-gameEvents:
-			dt := time.Now().Sub(lastTick)
+	for _, b := range bots {
+		b.LogSummary()
+	}
+
+	log.Printf("Gracefully stopped")
+}
+
+// connectRegion connects to the agar.io region selected by the -region and
+// -gamemode flags. If -region is blank, every region with the gamemode is
+// probed and the one with the lowest latency is used. If connecting to a
+// region fails, the next best one is tried. It gives up with errStopped once
+// stop is closed.
+func connectRegion(stop <-chan struct{}) (*agario.Connection, error) {
+	log.Printf("Getting region info...")
+	info, err := agario.GetInfo()
+	if err != nil {
+		return nil, err
+	}
 
-			ig.Lock()
+	var candidates []agario.Region
+	for _, r := range info.Regions {
+		if (*region != "" && r.Region != *region) || r.GameMode != *gamemode {
+			continue
+		}
 
-			ka.Update(dt)
-			//ai.Update(dt)
+		candidates = append(candidates, r)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unable to find region %q with gamemode %q", *region, *gamemode)
+	}
+
+	ranked, err := rankRegions(candidates, stop)
+	if err != nil {
+		return nil, err
+	}
 
-			ig.Unlock()
+	for _, r := range ranked {
+		log.Printf("Connecting to %s:%s...", r.Region, r.GameMode)
 
-			lastTick = time.Now()
+		c, err := connectWithin(r.Connect, 0, stop)
+		if err == errStopped {
+			return nil, err
 		}
+		if err != nil {
+			log.Printf("WARNING: unable to connect to %s:%s: %s", r.Region, r.GameMode, err)
+			demoteRegion(r)
+			continue
+		}
+
+		log.Printf("Connected. Server IP: %s", c.Addr)
+		return c, nil
 	}
 
-	log.Printf("Gracefully stopped")
+	return nil, fmt.Errorf("unable to connect to any region with gamemode %q", *gamemode)
 }
 
+// startLocalServer starts a local server and returns the address that it
+// listens on.
+func startLocalServer() string {
+	seed := *localSeed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+
+	l, err := net.Listen("tcp", *localAddr)
+	if err != nil {
+		log.Fatalf("Unable to start local server: %s", err)
+	}
+
+	server := newLocalServer(rand.New(rand.NewSource(seed)), *localNPCs)
+	go func() {
+		log.Fatal(server.Serve(l))
+	}()
+
+	log.Printf("Started local server at %s (seed %d)", l.Addr(), seed)
+	return l.Addr().String()
+}
+
+// connectAddr connects straight to the server at addr. It gives up with
+// errStopped once stop is closed.
+func connectAddr(addr, key string, stop <-chan struct{}) (*agario.Connection, error) {
+	log.Printf("Connecting to %s...", addr)
+
+	c, err := connectWithin(func() (*agario.Connection, error) {
+		return agario.Connect(addr, key)
+	}, 0, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Connected. Server IP: %s", c.Addr)
+	return c, nil
+}
+
+// randomNames are the nicknames that we spawn with. They can be set by the
+// config file.
 var randomNames = []string{"Derp", "Derp", "Derp", "Derp", "Derp", "Earth", "CIA", "Confederate", "Sanik", "Moon", "Qing Dynasty", "Matriarchy", "Patriarchy", "Feminism", "Steam", "Bait", "Vinesauce", "Sir", "Wojak", "Doge", "NASA", "Mars", "Pokerface", "8", "IRS"}
 
 func randomName() string {
@@ -77,13 +228,43 @@ func randomName() string {
 }
 
 var (
+	headless = flag.Bool("headless", false, "run without a window. Stop with SIGINT or SIGTERM.")
+
+	configPath = flag.String("config", "", "load settings from this JSON config file. Flags override it.")
+
 	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 	memprofile = flag.String("memprofile", "", "write memory profile to this file")
 
 	gamemode = flag.String("gamemode", "ffa", "agar.io gamemode")
-	region   = flag.String("region", "", "agar.io region (blank = closest)")
+	region   = flag.String("region", "", "agar.io region (blank = lowest latency)")
+
+	server    = flag.String("server", "", "connect straight to this websocket address instead of finding a region")
+	serverKey string
+
+	local     = flag.Bool("local", false, "run and connect to a local stand-in server instead of agar.io")
+	localAddr = flag.String("localaddr", "127.0.0.1:0", "address for the local server to listen on")
+	localNPCs = flag.Int("localnpcs", 10, "number of NPC players on the local server")
+	localSeed = flag.Int64("localseed", 0, "random seed of the local server (0 = random)")
+
+	simulate  = flag.Int("simulate", 0, "simulate this many lives of the AI in-process, report on them and exit")
+	simSeed   = flag.Int64("simseed", 1, "random seed of the first simulated life")
+	simLength = flag.Duration("simlength", 10*time.Minute, "longest time that a simulated life may last")
+	simNPCs   = flag.Int("simnpcs", 10, "number of NPC players in each simulated world")
+
+	recordPath  = flag.String("record", "", "record every message sent to and received from -server or -local to this file")
+	replayPath  = flag.String("replay", "", "replay a recording made with -record instead of connecting")
+	replaySpeed = flag.Float64("replayspeed", 1, "speed to replay at, relative to real time (0 = as fast as possible)")
+
+	bots = flag.Int("bots", 1, "number of bots to run, each with its own connection")
+
+	strategyList = flag.String("strategies", defaultStrategies, "comma separated list of AI strategies, in order of preference")
 )
 
+func init() {
+	flag.StringVar(&serverKey, "key", "", "key to connect to -server with")
+	flag.StringVar(&serverKey, "token", "", "alias for -key")
+}
+
 func main() {
 	log.SetFlags(log.Lshortfile)
 
@@ -91,6 +272,27 @@ func main() {
 
 	flag.Parse()
 
+	if *configPath != "" {
+		c, err := loadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Invalid config: %s", err)
+		}
+		c.apply()
+		go watchConfig(*configPath)
+
+		log.Printf("Loaded config from %s", *configPath)
+	}
+
+	strategies, err := parseStrategies(*strategyList)
+	if err != nil {
+		log.Fatalf("Invalid -strategies: %s", err)
+	}
+
+	if *simulate > 0 {
+		reportSimulation(*simSeed, *simulate, *simNPCs, *simLength, strategies)
+		return
+	}
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -111,71 +313,80 @@ func main() {
 		}
 	}()
 
-	log.Printf("Getting current location...")
-	desiredLocation := make(chan string, 1)
-	if *region == "" {
-		go func() {
-			curLocation, recommendedServer, err := agario.GetCurrentLocation()
-			if err != nil {
-				panic(err)
-			}
+	// Stop on SIGINT or SIGTERM from here on, so that connecting can be
+	// interrupted too and we still clean up and log a summary.
+	quitChan := make(chan struct{})
+	var quitOnce sync.Once
+	quit := func() {
+		quitOnce.Do(func() {
+			close(quitChan)
+		})
+	}
+	defer stopOnSignal(quit)()
 
-			desiredLocation <- recommendedServer
+	if *replayPath != "" {
+		r, err := newReplayer(*replayPath, *replaySpeed)
+		if err != nil {
+			log.Fatalf("Unable to open replay: %s", err)
+		}
 
-			if len(recommendedServer) == 0 {
-				log.Printf("WARNING: could not find desired region for %s", curLocation)
-				return
-			}
+		addr := serveLocal(r)
+		log.Printf("Replaying %s", *replayPath)
 
-			log.Printf("Got location: %s", curLocation)
-			log.Printf("Recommended server: %s", recommendedServer)
-		}()
-	} else {
-		desiredLocation <- *region
+		run([]*bot{newBot("", replaySessions(r, addr), strategies)}, quitChan, quit)
+		return
 	}
 
-	log.Printf("Getting region info...")
-	info, err := agario.GetInfo()
-	if err != nil {
-		panic(err)
+	if *bots < 1 {
+		log.Fatalf("Invalid -bots: must be at least 1")
 	}
 
-	var c *agario.Connection
-
-	regionName := <-desiredLocation
-	for _, region := range info.Regions {
-		if (regionName != "" && region.Region != regionName) || region.GameMode != *gamemode {
-			continue
+	// addr and key are the server to connect straight to, if any
+	var addr, key string
+	if *server != "" {
+		if *local {
+			log.Fatalf("-server and -local can't be used together")
 		}
+		addr, key = *server, serverKey
+	}
+	if *local {
+		addr = startLocalServer()
+	}
 
-		log.Printf("Connecting to %s:%s...", region.Region, region.GameMode)
+	if *recordPath != "" {
+		if *bots > 1 {
+			log.Fatalf("-record can only be used with a single bot")
+		}
+		// The agario package connects to regions by itself, so there's no
+		// way to put the recording proxy in between
+		if addr == "" {
+			log.Fatalf("-record can only be used with -server or -local")
+		}
 
-		c, err = region.Connect()
+		rec, err := newRecorder(*recordPath)
 		if err != nil {
-			panic(err)
+			log.Fatalf("Unable to create recording: %s", err)
 		}
+		defer rec.Close()
 
-		log.Printf("Connected. Server IP: %s", c.Addr)
-		break
-	}
-	if c == nil {
-		log.Printf("Unable to find region %s with gamemode %s", regionName, gamemode)
-		os.Exit(1)
+		addr = startRecordingProxy(addr, rec)
+		log.Printf("Recording to %s", *recordPath)
 	}
 
-	//defer c.Close()
-
-	g := agario.NewGame(c)
-	//defer g.Close()
-
-	run(g)
+	connect := connectRegion
+	if addr != "" {
+		connect = func(stop <-chan struct{}) (*agario.Connection, error) {
+			return connectAddr(addr, key, stop)
+		}
+	}
 
-	if *memprofile != "" {
-		f, err := os.Create(*memprofile)
-		if err != nil {
-			log.Fatal(err)
+	if *bots == 1 {
+		run([]*bot{newBot("", connectionSessions(connect), strategies)}, quitChan, quit)
+	} else {
+		all := make([]*bot, *bots)
+		for i := range all {
+			all[i] = newBot("bot "+strconv.Itoa(i+1), connectionSessions(connect), strategies)
 		}
-		pprof.WriteHeapProfile(f)
-		f.Close()
+		run(all, quitChan, quit)
 	}
 }
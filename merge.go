@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// mergeBaseCooldown and mergeCooldownPerMass estimate how long a cell has
+	// to wait after a split before it can merge again.
+	mergeBaseCooldown    = 30 * time.Second
+	mergeCooldownPerMass = 23 * time.Millisecond
+
+	// recombineLead is how long before the merge cooldown ends that we start
+	// steering our cells back together.
+	recombineLead = 2 * time.Second
+
+	// maxHuntCells is the most cells that we may end up with by splitting to
+	// hunt. A split can double the number of our cells, so we only split to
+	// hunt while we have at most half as many.
+	maxHuntCells = 4
+)
+
+// mergeCooldown estimates how long a cell of the given size has to wait after
+// a split before it can merge.
+func mergeCooldown(size int32) time.Duration {
+	return mergeBaseCooldown + time.Duration(sizeToMass(size)*float32(mergeCooldownPerMass))
+}
+
+// updateSplitTimes records when each of our cells was last split. A cell that
+// appears next to cells we already knew about was created by a split, and the
+// split restarts the merge cooldown of every cell that we have.
+func (ai *AI) updateSplitTimes() {
+	if ai.splitTimes == nil {
+		ai.splitTimes = make(map[uint32]time.Duration)
+	}
+
+	// After a respawn none of our cells are known, so nothing was split
+	var known, unknown int
+	for _, cell := range ai.OwnCells {
+		if _, ok := ai.splitTimes[cell.ID]; ok {
+			known++
+		} else {
+			unknown++
+		}
+	}
+	split := known > 0 && unknown > 0
+
+	current := make(map[uint32]struct{}, len(ai.OwnCells))
+	for _, cell := range ai.OwnCells {
+		current[cell.ID] = struct{}{}
+
+		if _, known := ai.splitTimes[cell.ID]; !known {
+			// Cells that we spawned with can merge straight away
+			ai.splitTimes[cell.ID] = -mergeCooldown(cell.Size)
+		}
+		if split {
+			ai.splitTimes[cell.ID] = ai.clock
+		}
+	}
+
+	for id := range ai.splitTimes {
+		if _, ok := current[id]; !ok {
+			delete(ai.splitTimes, id)
+		}
+	}
+
+	ai.MergeIn = 0
+	for _, cell := range ai.OwnCells {
+		if remaining := ai.mergeRemaining(cell); remaining > ai.MergeIn {
+			ai.MergeIn = remaining
+		}
+	}
+
+	if len(ai.OwnCells) > 1 {
+		ai.addStatusMessage(fmt.Sprintf("Merge in %.1fs", ai.MergeIn.Seconds()))
+	}
+}
+
+// mergeRemaining returns how long it will be until cell can merge again.
+func (ai *AI) mergeRemaining(cell *agario.Cell) time.Duration {
+	remaining := ai.splitTimes[cell.ID] + mergeCooldown(cell.Size) - ai.clock
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// recombineSoon reports whether our cells are split and about to be able to
+// merge.
+func (ai *AI) recombineSoon() bool {
+	return len(ai.OwnCells) > 1 && ai.MergeIn <= recombineLead
+}
+
+// getOwnCentroid returns the mass weighted center of our cells.
+func (ai *AI) getOwnCentroid() mgl32.Vec2 {
+	var (
+		centroid  mgl32.Vec2
+		totalMass float32
+	)
+	for _, cell := range ai.OwnCells {
+		mass := sizeToMass(cell.Size)
+		centroid = centroid.Add(cell.Position.Mul(mass))
+		totalMass += mass
+	}
+
+	if totalMass == 0 {
+		return ai.Me.Position
+	}
+	return centroid.Mul(1 / totalMass)
+}
+
+// recombine steers our split cells together when they're about to be able to
+// merge
+func (ai *AI) recombine() (float64, Action) {
+	if len(ai.OwnCells) < 2 {
+		ai.addStatusMessage("Not recombining: Not split")
+		return 0, nil
+	}
+	if !ai.recombineSoon() {
+		ai.addStatusMessage(fmt.Sprintf("Not recombining: Can't merge for %.1fs", ai.MergeIn.Seconds()))
+		return 0, nil
+	}
+
+	return priorityNormal, func() {
+		centroid := ai.getOwnCentroid()
+
+		ai.addStatusMessage("Recombining")
+		ai.Path = []mgl32.Vec2{ai.Me.Position, centroid}
+		ai.setTargetPos(centroid)
+		ai.State = stateIdle
+	}
+}
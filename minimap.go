@@ -0,0 +1,142 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ajhager/engi"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// minimapSize is the length of the longer side of the minimap in pixels.
+	minimapSize   = 200
+	minimapMargin = 10
+	minimapColor  = 0x000000
+	minimapAlpha  = 0.5
+
+	// minimapBackgroundSize is the side of the square cut out of the middle
+	// of the circle texture to draw the background with. It lies wholly
+	// inside the circle.
+	minimapBackgroundSize = circleSize / 2
+
+	// minimapSightingTTL is how long a player is remembered on the minimap
+	// after it was last seen, fading out over time.
+	minimapSightingTTL = time.Minute
+
+	minimapMinDotSize = 3
+	ownColor          = 0xffffff
+	targetColor       = 0xffff30
+)
+
+// sighting is where a player was last seen.
+type sighting struct {
+	Position mgl32.Vec2
+	Size     int32
+	Color    uint32
+	At       time.Time
+}
+
+// newMinimapBackground cuts an opaque square out of the middle of the circle
+// texture.
+func newMinimapBackground(circle *engi.Texture) *engi.Region {
+	offset := (circleSize - minimapBackgroundSize) / 2
+	return engi.NewRegion(circle, offset, offset, minimapBackgroundSize, minimapBackgroundSize)
+}
+
+// updateSightings remembers where every player in view that is at least as
+// large as our largest cell is. Players that haven't been seen for a while are
+// forgotten.
+func (g *Game) updateSightings() {
+	if g.sightingsOf != g.g || g.sightings == nil {
+		g.sightings = make(map[uint32]*sighting)
+		g.sightingsOf = g.g
+	}
+
+	var largest *agario.Cell
+	if g.ai != nil {
+		largest = g.ai.getLargestOwnCell()
+	}
+
+	now := time.Now()
+	if largest != nil {
+		for _, c := range g.g.Cells {
+			if _, own := g.g.MyIDs[c.ID]; own || c.IsVirus || c.Size < largest.Size {
+				continue
+			}
+
+			red, green, blue, _ := c.Color.RGBA()
+			g.sightings[c.ID] = &sighting{
+				Position: c.Position,
+				Size:     c.Size,
+				Color:    ((red & 0xFF) << 16) | ((green & 0xFF) << 8) | (blue & 0xFF),
+				At:       now,
+			}
+		}
+	}
+
+	for id, s := range g.sightings {
+		if now.Sub(s.At) > minimapSightingTTL {
+			delete(g.sightings, id)
+		}
+	}
+}
+
+// renderMinimap draws the whole board in the bottom right corner of the screen
+// with our cells, the last known positions of large players and where the AI
+// is heading.
+func (g *Game) renderMinimap() {
+	g.updateSightings()
+
+	board := g.g.Board
+	boardW, boardH := float32(board.Right-board.Left), float32(board.Bottom-board.Top)
+	if boardW <= 0 || boardH <= 0 {
+		return
+	}
+
+	scale := minimapSize / boardW
+	if boardH > boardW {
+		scale = minimapSize / boardH
+	}
+	w, h := boardW*scale, boardH*scale
+	left, top := g.W-w-minimapMargin, g.H-h-minimapMargin
+
+	toMinimap := func(p mgl32.Vec2) (float32, float32) {
+		return left + (p.X()-float32(board.Left))*scale, top + (p.Y()-float32(board.Top))*scale
+	}
+
+	g.batch.Draw(g.minimapBackground, left, top, 0, 0, w/minimapBackgroundSize, h/minimapBackgroundSize, 0, minimapColor, minimapAlpha)
+
+	now := time.Now()
+	for _, s := range g.sightings {
+		x, y := toMinimap(s.Position)
+		fade := 1 - float32(now.Sub(s.At))/float32(minimapSightingTTL)
+		g.drawMinimapDot(x, y, float32(s.Size)*scale, s.Color, fade)
+	}
+
+	for id := range g.g.MyIDs {
+		c, ok := g.g.Cells[id]
+		if !ok {
+			continue
+		}
+
+		x, y := toMinimap(c.Position)
+		g.drawMinimapDot(x, y, float32(c.Size)*scale, ownColor, 1)
+	}
+
+	if g.ai != nil && len(g.ai.Path) > 0 {
+		x, y := toMinimap(g.ai.Path[len(g.ai.Path)-1])
+		g.drawMinimapDot(x, y, 0, targetColor, 1)
+	}
+}
+
+// drawMinimapDot draws a dot on the minimap. It's never smaller than
+// minimapMinDotSize so that small cells can still be seen.
+func (g *Game) drawMinimapDot(x, y, size float32, col uint32, alpha float32) {
+	if size < minimapMinDotSize {
+		size = minimapMinDotSize
+	}
+
+	scale := size / circleSize
+	g.batch.Draw(g.circle, x, y, 0.5, 0.5, scale, scale, 0, col, alpha)
+}
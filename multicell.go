@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+// steerHeadings is the number of alternative cursor positions that steer
+// considers when the desired one puts one of our cells in danger.
+const steerHeadings = 16
+
+// predictOwnPosition returns where one of our cells will be after the given
+// number of seconds of moving towards cursor.
+func (ai *AI) predictOwnPosition(own *agario.Cell, cursor mgl32.Vec2, seconds float32) mgl32.Vec2 {
+	delta := cursor.Sub(own.Position)
+	dist := delta.Len()
+
+	travel := own.Speed() * serverTicksPerSecond * seconds
+	if travel >= dist {
+		return ai.clampToBoard(cursor)
+	}
+
+	return ai.clampToBoard(own.Position.Add(delta.Mul(travel / dist)))
+}
+
+// scoreCursor scores moving all of our cells towards cursor when we actually
+// want to get to desired. safety is the group clearance from every threat and
+// progress is how much closer to desired our cells get, weighted by mass.
+func (ai *AI) scoreCursor(cursor, desired mgl32.Vec2) (safety, progress float32) {
+	safety = ai.groupClearance(cursor, ai.Threats)
+
+	horizon := float32(fleeHorizon.Seconds())
+
+	var totalMass float32
+	for _, own := range ai.OwnCells {
+		mass := sizeToMass(own.Size)
+		totalMass += mass
+
+		before := desired.Sub(own.Position).Len()
+		after := desired.Sub(ai.predictOwnPosition(own, cursor, horizon)).Len()
+		progress += (before - after) * mass
+	}
+	if totalMass > 0 {
+		progress /= totalMass
+	}
+
+	return safety, progress
+}
+
+// steer returns the cursor position that is best for all of our cells when
+// we want to move to desired. While we're a single cell, or none of our cells
+// are threatened by moving there, that's desired itself. Otherwise every cell
+// is scored on its own, so that a split cell is never led into a predator
+// just because the group as a whole would be safe.
+func (ai *AI) steer(desired mgl32.Vec2) mgl32.Vec2 {
+	if len(ai.OwnCells) < 2 || len(ai.Threats) == 0 {
+		return desired
+	}
+
+	bestSafety, bestProgress := ai.scoreCursor(desired, desired)
+	if bestSafety >= 0 {
+		return desired
+	}
+
+	best := desired
+	for i := 0; i < steerHeadings; i++ {
+		angle := 2 * math.Pi * float64(i) / steerHeadings
+		heading := mgl32.Vec2{float32(math.Cos(angle)), float32(math.Sin(angle))}
+		cursor := ai.clampToBoard(ai.Me.Position.Add(heading.Mul(fleeDistance)))
+
+		safety, progress := ai.scoreCursor(cursor, desired)
+
+		// Any cursor that keeps every cell safe beats one that doesn't. After
+		// that, get as close to where we wanted to go as possible.
+		clamped, bestClamped := float32(math.Min(float64(safety), 0)), float32(math.Min(float64(bestSafety), 0))
+		if clamped > bestClamped || (clamped == bestClamped && progress > bestProgress) {
+			best = cursor
+			bestSafety = safety
+			bestProgress = progress
+		}
+	}
+
+	if best != desired {
+		ai.addStatusMessage("Steering to keep all of our cells out of reach")
+	}
+
+	return best
+}
+
+// getCellInDanger returns the own cell that the threats to it can get closest
+// to eating. Without any threats that's our smallest cell, since it's the
+// easiest one to eat.
+func (ai *AI) getCellInDanger() *agario.Cell {
+	horizon := float32(fleeHorizon.Seconds())
+
+	var (
+		danger *agario.Cell
+		margin = float32(math.MaxFloat32)
+	)
+	for _, t := range ai.Threats {
+		m := closestApproach(t.Target.Position, t, horizon) - t.Radius
+		if danger == nil || m < margin || (m == margin && t.Target.ID < danger.ID) {
+			danger = t.Target
+			margin = m
+		}
+	}
+
+	if danger == nil {
+		return ai.SmallestOwnCell
+	}
+	return danger
+}
+
+// setTargetPos moves the cursor towards position, adjusted so that it is safe
+// for every one of our cells.
+func (ai *AI) setTargetPos(position mgl32.Vec2) {
+	target := ai.steer(position)
+	ai.c.SetTargetPos(target.X(), target.Y())
+}
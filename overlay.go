@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+// Colours of the debug overlay
+const (
+	predatorColor = 0xff3030
+	preyColor     = 0x30ff30
+	foodColor     = 0xffff30
+	pathColor     = 0xffffff
+	textColor     = 0xffffff
+)
+
+const (
+	// highlightWidth is how far a highlight sticks out around its cell.
+	highlightWidth = 8
+	highlightAlpha = 0.6
+
+	pathDotSize    = 6
+	pathDotSpacing = 20
+
+	fontLineHeight = 20
+	textMargin     = 10
+)
+
+// getHighlights returns the colour to highlight each cell that the AI has
+// classified with.
+func (g *Game) getHighlights() map[*agario.Cell]uint32 {
+	if g.ai == nil {
+		return nil
+	}
+
+	highlights := make(map[*agario.Cell]uint32, len(g.ai.Predators)+len(g.ai.Prey)+len(g.ai.Food))
+	for _, c := range g.ai.Food {
+		highlights[c] = foodColor
+	}
+	for _, c := range g.ai.Prey {
+		highlights[c] = preyColor
+	}
+	for _, c := range g.ai.Predators {
+		highlights[c] = predatorColor
+	}
+	return highlights
+}
+
+// drawHighlight draws a ring of col around c. It must be drawn before c.
+func (g *Game) drawHighlight(c *agario.Cell, col uint32) {
+	scale := (float32(c.Size) + highlightWidth) / circleSize * g.camera.Zoom
+	x, y := g.worldToScreen(c.Position)
+	g.batch.Draw(g.circle, x, y, 0.5, 0.5, scale, scale, 0, col, highlightAlpha)
+}
+
+// renderPath draws the path that the AI is following as a dotted line.
+func (g *Game) renderPath() {
+	if g.ai == nil || len(g.ai.Path) < 2 {
+		return
+	}
+
+	scale := float32(pathDotSize) / circleSize
+	for i := 1; i < len(g.ai.Path); i++ {
+		from, to := g.ai.Path[i-1], g.ai.Path[i]
+
+		segment := to.Sub(from)
+		length := segment.Len()
+		if length == 0 {
+			continue
+		}
+		step := segment.Mul(pathDotSpacing / length)
+
+		pos := from
+		for traveled := float32(0); traveled < length; traveled += pathDotSpacing {
+			g.drawDot(pos, scale, pathColor)
+			pos = pos.Add(step)
+		}
+	}
+
+	g.drawDot(g.ai.Path[len(g.ai.Path)-1], scale*2, pathColor)
+}
+
+func (g *Game) drawDot(pos mgl32.Vec2, scale float32, col uint32) {
+	x, y := g.worldToScreen(pos)
+	g.batch.Draw(g.circle, x, y, 0.5, 0.5, scale, scale, 0, col, 1)
+}
+
+// renderStatus writes the AI's state, strategy and status messages in the top
+// left corner of the screen.
+func (g *Game) renderStatus() {
+	if g.ai == nil {
+		return
+	}
+
+	state := stateNames[g.ai.State]
+	if g.ai.Strategy != "" {
+		state += " (" + g.ai.Strategy + ")"
+	}
+
+	y := float32(textMargin)
+	g.font.Print(g.batch, state, textMargin, y, textColor)
+	for _, status := range g.ai.Status {
+		y += fontLineHeight
+		g.font.Print(g.batch, status, textMargin, y, textColor)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// aiParams holds the tuning of the AI that can be changed while it's running.
+// A set of parameters is never modified once it's in use; changing them swaps
+// in a whole new set, which the AI picks up on its next update.
+type aiParams struct {
+	// EatSizeRequirement is how many times larger than another cell a cell
+	// must be to eat it.
+	EatSizeRequirement float32 `json:"eatSizeRequirement"`
+	// FoodMaxSize is the largest size of a food pellet. Players start at 10
+	// and can't fall below it.
+	FoodMaxSize int32 `json:"foodMaxSize"`
+	// BorderCosts are the costs of the rows of the cost map along the edges
+	// of the board, from the outermost row inwards, as fractions of
+	// costDoNotPass.
+	BorderCosts []float32 `json:"borderCosts"`
+	// PredatorPadding is added to the size of a predator when it's marked on
+	// the cost map.
+	PredatorPadding int `json:"predatorPadding"`
+	// SplitCooldown is the shortest time between two splits.
+	SplitCooldown duration `json:"splitCooldown"`
+}
+
+var defaultAIParams = &aiParams{
+	EatSizeRequirement: 1.25,
+	FoodMaxSize:        20,
+	BorderCosts:        []float32{1.0 / 2, 1.0 / 3, 1.0 / 4, 1.0 / 5},
+	PredatorPadding:    100,
+	SplitCooldown:      duration(250 * time.Millisecond),
+}
+
+var currentAIParams atomic.Value
+
+// loadAIParams returns the parameters that are currently in effect.
+func loadAIParams() *aiParams {
+	if p, ok := currentAIParams.Load().(*aiParams); ok {
+		return p
+	}
+	return defaultAIParams
+}
+
+// storeAIParams puts p into effect. p must not be modified afterwards.
+func storeAIParams(p *aiParams) {
+	currentAIParams.Store(p)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type frameKind byte
+
+const (
+	// frameInbound is a message from the server.
+	frameInbound frameKind = iota
+	// frameOutbound is a message that we sent to the server.
+	frameOutbound
+	// frameClosed marks the end of a connection.
+	frameClosed
+)
+
+// frame is a single entry of a recording: a websocket message exactly as it
+// was sent or received.
+type frame struct {
+	// At is the time since the recording started.
+	At   time.Duration
+	Kind frameKind
+	Data []byte
+}
+
+// recorder writes every websocket message of a bot's connections to a file, so
+// that they can be replayed later.
+type recorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	enc   *gob.Encoder
+	start time.Time
+}
+
+func newRecorder(path string) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	return &recorder{
+		f:     f,
+		w:     w,
+		enc:   gob.NewEncoder(w),
+		start: time.Now(),
+	}, nil
+}
+
+// Record writes a single message to the recording.
+func (r *recorder) Record(kind frameKind, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := &frame{
+		At:   time.Since(r.start),
+		Kind: kind,
+		Data: data,
+	}
+	if err := r.enc.Encode(f); err != nil {
+		log.Printf("WARNING: failed to write recording: %s", err)
+	}
+}
+
+// Close flushes the recording and closes its file.
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// recordingProxy relays websocket connections to a server and records every
+// message that passes through it. The bot connects to the proxy instead of the
+// server, so the recording holds the same messages that the agario package
+// decodes.
+type recordingProxy struct {
+	upstream string
+	rec      *recorder
+
+	upgrader websocket.Upgrader
+}
+
+// startRecordingProxy starts a proxy to the server at upstream that records to
+// rec, and returns the address that it listens on.
+func startRecordingProxy(upstream string, rec *recorder) string {
+	return serveLocal(&recordingProxy{
+		upstream: upstream,
+		rec:      rec,
+
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	})
+}
+
+func (p *recordingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Recording proxy: upgrade failed: %s", err)
+		return
+	}
+	defer client.Close()
+
+	server, _, err := websocket.DefaultDialer.Dial(websocketURL(p.upstream), http.Header{"Origin": r.Header["Origin"]})
+	if err != nil {
+		log.Printf("Recording proxy: unable to connect to %s: %s", p.upstream, err)
+		return
+	}
+	defer server.Close()
+
+	// Closing both connections once either side fails stops the other relay
+	done := make(chan struct{}, 2)
+	go p.relay(server, client, frameInbound, done)
+	go p.relay(client, server, frameOutbound, done)
+	<-done
+
+	p.rec.Record(frameClosed, nil)
+}
+
+// relay passes every message from one connection on to the other, recording
+// it as kind, until either of them fails.
+func (p *recordingProxy) relay(from, to *websocket.Conn, kind frameKind, done chan<- struct{}) {
+	defer func() {
+		done <- struct{}{}
+	}()
+
+	for {
+		typ, msg, err := from.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		p.rec.Record(kind, msg)
+		if err := to.WriteMessage(typ, msg); err != nil {
+			return
+		}
+	}
+}
+
+// replayer is a websocket server that plays back a recording. Each connection
+// to it is sent the server's messages of the next recorded connection, at the
+// pace that they were recorded at, so that the agario package decodes them the
+// same way that it did live. The messages that the client sends are discarded.
+type replayer struct {
+	f   *os.File
+	dec *gob.Decoder
+
+	// speed is how many times faster than real time the recording is
+	// replayed. Zero replays it as fast as possible.
+	speed float64
+
+	upgrader websocket.Upgrader
+
+	// playing is held while a connection is being played to.
+	playing sync.Mutex
+
+	mu sync.Mutex
+	// start is when the replay started. at is when the last message sent was
+	// recorded.
+	start    time.Time
+	at       time.Duration
+	finished bool
+}
+
+func newReplayer(path string, speed float64) (*replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &replayer{
+		f:   f,
+		dec: gob.NewDecoder(bufio.NewReader(f)),
+
+		speed: speed,
+
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	}, nil
+}
+
+// ServeHTTP plays the next recorded connection to the client. It returns at
+// the end of that connection, at the end of the recording or once the client
+// goes away.
+func (r *replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		log.Printf("Replay: upgrade failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		// The client's messages are only read to notice it going away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				close(gone)
+				return
+			}
+		}
+	}()
+
+	r.playing.Lock()
+	defer r.playing.Unlock()
+
+	r.mu.Lock()
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	start := r.start
+	r.mu.Unlock()
+
+	for {
+		var f frame
+		if err := r.dec.Decode(&f); err != nil {
+			if err != io.EOF {
+				log.Printf("WARNING: failed to read recording: %s", err)
+			}
+			log.Printf("Replay finished")
+
+			r.mu.Lock()
+			r.finished = true
+			r.mu.Unlock()
+			r.f.Close()
+			return
+		}
+
+		switch f.Kind {
+		case frameClosed:
+			return
+		case frameOutbound:
+			logRecordedCommand(f.Data)
+			continue
+		}
+
+		if r.speed > 0 {
+			due := start.Add(time.Duration(float64(f.At) / r.speed))
+			select {
+			case <-time.After(due.Sub(time.Now())):
+			case <-gone:
+				return
+			}
+		}
+
+		r.mu.Lock()
+		r.at = f.At
+		r.mu.Unlock()
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, f.Data); err != nil {
+			return
+		}
+	}
+}
+
+// Clock returns when the last message sent to the client was recorded.
+func (r *replayer) Clock() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.at
+}
+
+// Finished reports whether every recorded connection has been played.
+func (r *replayer) Finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.finished
+}
+
+// logRecordedCommand logs a command that we sent while recording. Target
+// positions are sent on every tick, so they are left out.
+func logRecordedCommand(msg []byte) {
+	if len(msg) == 0 {
+		return
+	}
+
+	switch msg[0] {
+	case opNickname:
+		log.Printf("Replay: recorded nickname command %q", decodeUTF16(msg[1:]))
+	case opSplit:
+		log.Printf("Replay: recorded split command")
+	case opEject:
+		log.Printf("Replay: recorded eject command")
+	}
+}
+
+// serveLocal serves h on a free local port and returns the address that it
+// listens on.
+func serveLocal(h http.Handler) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Unable to listen locally: %s", err)
+	}
+
+	go func() {
+		log.Fatal(http.Serve(l, h))
+	}()
+
+	return l.Addr().String()
+}
+
+// websocketURL returns the URL of the websocket server at addr, which may
+// leave out the scheme.
+func websocketURL(addr string) string {
+	if strings.Contains(addr, "://") {
+		return addr
+	}
+	return "ws://" + addr
+}
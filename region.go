@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// regionProbePings is how many round trips are timed to each region. The
+	// median of them is used.
+	regionProbePings   = 3
+	regionProbeTimeout = 5 * time.Second
+	// regionRankingTTL is how long a ranking is reused for before the regions
+	// are probed again.
+	regionRankingTTL = 10 * time.Minute
+)
+
+// regionProbe is the result of probing a single region.
+type regionProbe struct {
+	Region agario.Region
+	Addr   string
+
+	// Connect is how long it took to get a connection to the region. RTT is
+	// the median time to open a TCP connection to its server.
+	Connect time.Duration
+	RTT     time.Duration
+
+	Err error
+}
+
+// errConnectTimeout is returned when connecting takes too long.
+var errConnectTimeout = errors.New("timed out")
+
+// probeRegion connects to r and times round trips to the server that it hands
+// out. It gives up with errStopped once stop is closed.
+func probeRegion(r agario.Region, stop <-chan struct{}) *regionProbe {
+	p := &regionProbe{Region: r}
+
+	start := time.Now()
+	c, err := connectWithin(r.Connect, regionProbeTimeout, stop)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	p.Connect = time.Since(start)
+	p.Addr = c.Addr
+	c.Close()
+
+	addr := c.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+
+	dialer := net.Dialer{
+		Timeout: regionProbeTimeout,
+		Cancel:  stop,
+	}
+
+	rtts := make([]time.Duration, 0, regionProbePings)
+	for i := 0; i < regionProbePings; i++ {
+		start := time.Now()
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			p.Err = err
+			return p
+		}
+		rtts = append(rtts, time.Since(start))
+		conn.Close()
+	}
+
+	sort.Sort(durationSlice(rtts))
+	p.RTT = rtts[len(rtts)/2]
+
+	return p
+}
+
+// connectWithin connects with connect, giving up with errConnectTimeout after
+// timeout or with errStopped once stop is closed. A timeout of zero waits for as
+// long as connecting takes. A connection that is made after giving up is
+// closed.
+func connectWithin(connect func() (*agario.Connection, error), timeout time.Duration, stop <-chan struct{}) (*agario.Connection, error) {
+	type result struct {
+		c   *agario.Connection
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := connect()
+		done <- result{c, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	err := errStopped
+	select {
+	case res := <-done:
+		return res.c, res.err
+	case <-expired:
+		err = errConnectTimeout
+	case <-stop:
+	}
+
+	go func() {
+		if res := <-done; res.err == nil {
+			res.c.Close()
+		}
+	}()
+	return nil, err
+}
+
+// probeRegions probes every region at once and returns them ranked from the
+// lowest round trip time to the highest. Regions that couldn't be probed come
+// last.
+func probeRegions(regions []agario.Region, stop <-chan struct{}) []*regionProbe {
+	probes := make([]*regionProbe, len(regions))
+
+	var wg sync.WaitGroup
+	for i, r := range regions {
+		wg.Add(1)
+		go func(i int, r agario.Region) {
+			defer wg.Done()
+			probes[i] = probeRegion(r, stop)
+		}(i, r)
+	}
+	wg.Wait()
+
+	sort.Stable(probeSlice(probes))
+	return probes
+}
+
+// logRanking logs a table of ranked probes.
+func logRanking(probes []*regionProbe) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tREGION\tSERVER\tCONNECT\tRTT")
+	for i, p := range probes {
+		if p.Err != nil {
+			fmt.Fprintf(w, "%d\t%s:%s\t%s\t-\tfailed: %s\n", i+1, p.Region.Region, p.Region.GameMode, p.Addr, p.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s:%s\t%s\t%s\t%s\n", i+1, p.Region.Region, p.Region.GameMode, p.Addr, p.Connect, p.RTT)
+	}
+	w.Flush()
+
+	log.Printf("Region ranking:")
+	for _, line := range bytes.Split(bytes.TrimRight(buf.Bytes(), "\n"), []byte("\n")) {
+		log.Printf("  %s", line)
+	}
+}
+
+// regionRanking is the last ranking of the regions, shared by every bot so that
+// they don't all probe at once. key identifies the regions that were ranked.
+var regionRanking struct {
+	sync.Mutex
+	key     string
+	regions []agario.Region
+	at      time.Time
+}
+
+// rankRegions returns the regions in order of preference, probing them if
+// there's more than one and they haven't been ranked recently. It gives up with
+// errStopped once stop is closed.
+func rankRegions(regions []agario.Region, stop <-chan struct{}) ([]agario.Region, error) {
+	if len(regions) < 2 {
+		return regions, nil
+	}
+
+	key := regionsKey(regions)
+
+	regionRanking.Lock()
+	defer regionRanking.Unlock()
+
+	if regionRanking.key == key && time.Since(regionRanking.at) < regionRankingTTL {
+		return append([]agario.Region(nil), regionRanking.regions...), nil
+	}
+
+	log.Printf("Probing %d regions...", len(regions))
+	probes := probeRegions(regions, stop)
+	select {
+	case <-stop:
+		return nil, errStopped
+	default:
+	}
+	logRanking(probes)
+
+	ranked := make([]agario.Region, len(probes))
+	for i, p := range probes {
+		ranked[i] = p.Region
+	}
+
+	if probes[0].Err != nil {
+		// Nothing could be probed, so fall back to the region that agar.io
+		// recommends for our location.
+		ranked = preferRegion(ranked, recommendedRegion())
+	}
+
+	regionRanking.key = key
+	regionRanking.regions = ranked
+	regionRanking.at = time.Now()
+
+	return append([]agario.Region(nil), ranked...), nil
+}
+
+// demoteRegion moves r to the end of the current ranking, so that it is tried
+// last until the regions are probed again.
+func demoteRegion(r agario.Region) {
+	regionRanking.Lock()
+	defer regionRanking.Unlock()
+
+	ranked := regionRanking.regions
+	for i, other := range ranked {
+		if other == r {
+			copy(ranked[i:], ranked[i+1:])
+			ranked[len(ranked)-1] = r
+			return
+		}
+	}
+}
+
+// regionsKey returns a string that identifies a set of regions, whatever their
+// order.
+func regionsKey(regions []agario.Region) string {
+	names := make([]string, len(regions))
+	for i, r := range regions {
+		names[i] = r.Region + ":" + r.GameMode
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// recommendedRegion returns the region that agar.io recommends for our
+// location, or "" if it can't be found.
+func recommendedRegion() string {
+	log.Printf("Getting location...")
+	location, recommended, err := agario.GetCurrentLocation()
+	if err != nil {
+		log.Printf("WARNING: could not get location: %s", err)
+		return ""
+	}
+	if recommended == "" {
+		log.Printf("WARNING: could not find desired region for %s", location)
+		return ""
+	}
+
+	log.Printf("Got location: %s (recommended region: %s)", location, recommended)
+	return recommended
+}
+
+// preferRegion moves the regions named name to the front of regions, keeping
+// the order of the rest.
+func preferRegion(regions []agario.Region, name string) []agario.Region {
+	if name == "" {
+		return regions
+	}
+
+	preferred := make([]agario.Region, 0, len(regions))
+	var rest []agario.Region
+	for _, r := range regions {
+		if r.Region == name {
+			preferred = append(preferred, r)
+		} else {
+			rest = append(rest, r)
+		}
+	}
+	return append(preferred, rest...)
+}
+
+// probeSlice sorts probes by round trip time, with failed probes last.
+type probeSlice []*regionProbe
+
+func (p probeSlice) Len() int { return len(p) }
+func (p probeSlice) Less(i, j int) bool {
+	a, b := p[i], p[j]
+	if (a.Err == nil) != (b.Err == nil) {
+		return a.Err == nil
+	}
+
+	return a.RTT < b.RTT
+}
+func (p probeSlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+type durationSlice []time.Duration
+
+func (p durationSlice) Len() int           { return len(p) }
+func (p durationSlice) Less(i, j int) bool { return p[i] < p[j] }
+func (p durationSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
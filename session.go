@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nightexcessive/agario"
+)
+
+// errNoMoreSessions is returned when there is nothing left to play, such as at
+// the end of a replay.
+var errNoMoreSessions = errors.New("no more sessions")
+
+// errStopped is returned when opening a session is given up on because we've
+// been told to stop.
+var errStopped = errors.New("stopped")
+
+// session is a single game played by the bot, from when it connects until the
+// connection is lost.
+type session struct {
+	Game      *agario.Game
+	Commander commander
+
+	// Events signals c after each batch of messages. It returns once the
+	// session has ended or stop is closed.
+	Events func(c chan<- struct{}, stop <-chan struct{})
+	// Clock returns the game time of the latest messages. Sessions that are
+	// played in real time leave it nil and the wall clock is used.
+	Clock func() time.Duration
+	// Close releases everything held by the session.
+	Close func()
+}
+
+// connectionSessions opens a new connection with connect for every session.
+func connectionSessions(connect func(stop <-chan struct{}) (*agario.Connection, error)) func(stop <-chan struct{}) (*session, error) {
+	return func(stop <-chan struct{}) (*session, error) {
+		c, err := connect(stop)
+		if err != nil {
+			return nil, err
+		}
+
+		g := agario.NewGame(c)
+		return &session{
+			Game:      g,
+			Commander: g,
+
+			Events: func(events chan<- struct{}, stop <-chan struct{}) {
+				handleGameEvents(events, stop, g)
+			},
+			Close: func() {
+				g.Close()
+				c.Close()
+			},
+		}, nil
+	}
+}
+
+// replaySessions plays back every connection recorded in r, which is served at
+// addr, as a session of its own.
+func replaySessions(r *replayer, addr string) func(stop <-chan struct{}) (*session, error) {
+	open := connectionSessions(func(stop <-chan struct{}) (*agario.Connection, error) {
+		return connectAddr(addr, "", stop)
+	})
+
+	return func(stop <-chan struct{}) (*session, error) {
+		if r.Finished() {
+			return nil, errNoMoreSessions
+		}
+
+		s, err := open(stop)
+		if err != nil {
+			return nil, err
+		}
+		s.Clock = r.Clock
+		return s, nil
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+// simStep is the fixed timestep of the simulator. It matches the tick rate of
+// the real servers.
+const simStep = time.Second / serverTicksPerSecond
+
+// simCommander sends the AI's commands straight to its player in the world.
+type simCommander struct {
+	player *worldPlayer
+}
+
+func (c *simCommander) SetTargetPos(x, y float32) {
+	c.player.Target = mgl32.Vec2{x, y}
+}
+
+func (c *simCommander) Split() {
+	c.player.Split()
+}
+
+func (c *simCommander) EjectMass() {
+	c.player.Eject()
+}
+
+func (c *simCommander) SendNickname(name string) {
+	c.player.Name = name
+}
+
+// Simulator runs the AI against a world in-process, without a connection or a
+// window. Everything is stepped at a fixed timestep from a seeded random number
+// generator, so a run with the same seed and strategies always plays out the
+// same way.
+type Simulator struct {
+	World *world
+	Game  *agario.Game
+	AI    *AI
+
+	player *worldPlayer
+}
+
+// NewSimulator creates a world with the given number of NPC players and an AI
+// that plays in it using strategies.
+func NewSimulator(seed int64, npcs int, strategies []namedStrategy) *Simulator {
+	w := newWorld(rand.New(rand.NewSource(seed)))
+	for i := 0; i < npcs; i++ {
+		w.AddNPC("NPC")
+	}
+
+	player := w.AddPlayer()
+
+	g := &agario.Game{
+		Cells: make(map[uint32]*agario.Cell),
+		MyIDs: make(map[uint32]struct{}),
+	}
+	g.Board.Right = float64(w.Width)
+	g.Board.Bottom = float64(w.Height)
+
+	return &Simulator{
+		World: w,
+		Game:  g,
+		AI: &AI{
+			g: g,
+			c: &simCommander{player},
+
+			Strategies: strategies,
+		},
+
+		player: player,
+	}
+}
+
+// Step advances the world by one timestep, shows the AI what its player can
+// see, and lets it act.
+func (s *Simulator) Step() {
+	s.World.Step(simStep)
+	s.World.Eaten = s.World.Eaten[:0]
+
+	s.syncGame()
+	s.AI.Update(simStep)
+}
+
+// syncGame fills the game with the cells that are visible to the AI's player,
+// the same way that the agario package does from a server's updates.
+func (s *Simulator) syncGame() {
+	center, halfW, halfH, alive := s.player.View()
+	if !alive {
+		for id := range s.Game.Cells {
+			delete(s.Game.Cells, id)
+		}
+		for id := range s.Game.MyIDs {
+			delete(s.Game.MyIDs, id)
+		}
+		return
+	}
+
+	visible := make(map[uint32]struct{})
+	for id, wc := range s.World.Cells {
+		if !wc.inView(center, halfW, halfH) {
+			continue
+		}
+		visible[id] = struct{}{}
+
+		cell, ok := s.Game.Cells[id]
+		if !ok {
+			cell = &agario.Cell{ID: id}
+			s.Game.Cells[id] = cell
+		}
+
+		cell.Position = wc.Position
+		cell.Size = int32(wc.Size())
+		cell.Color = wc.Color
+		cell.IsVirus = wc.Kind == kindVirus
+		if wc.Owner != nil {
+			cell.Name = wc.Owner.Name
+		}
+	}
+
+	for id := range s.Game.Cells {
+		if _, ok := visible[id]; !ok {
+			delete(s.Game.Cells, id)
+		}
+	}
+
+	for id := range s.Game.MyIDs {
+		delete(s.Game.MyIDs, id)
+	}
+	for _, c := range s.player.Cells {
+		s.Game.MyIDs[c.ID] = struct{}{}
+	}
+}
+
+// RunLife spawns the AI's player and steps the world until it dies or has
+// lived for maxDuration.
+func (s *Simulator) RunLife(maxDuration time.Duration) lifeResult {
+	s.World.Spawn(s.player, "Simulated")
+
+	var result lifeResult
+	for result.Duration < maxDuration {
+		s.Step()
+		result.Duration += simStep
+
+		if !s.player.Alive() {
+			result.Died = true
+			break
+		}
+
+		if mass := s.player.Mass(); mass > result.PeakMass {
+			result.PeakMass = mass
+		}
+	}
+
+	return result
+}
+
+// simulateLives runs the given number of lives of the AI, each in a fresh
+// world seeded from seed, and returns how each of them went.
+func simulateLives(seed int64, lives, npcs int, maxDuration time.Duration, strategies []namedStrategy) []lifeResult {
+	results := make([]lifeResult, 0, lives)
+	for i := 0; i < lives; i++ {
+		s := NewSimulator(seed+int64(i), npcs, strategies)
+		results = append(results, s.RunLife(maxDuration))
+	}
+	return results
+}
+
+// reportSimulation runs simulated lives and logs how they went.
+func reportSimulation(seed int64, lives, npcs int, maxDuration time.Duration, strategies []namedStrategy) {
+	log.Printf("Simulating %d lives (seed %d)...", lives, seed)
+
+	start := time.Now()
+	results := simulateLives(seed, lives, npcs, maxDuration, strategies)
+
+	log.Printf("Simulated %d lives in %s", len(results), time.Since(start))
+	logLives(log.Printf, results)
+}
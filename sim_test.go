@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	testLives      = 3
+	testNPCs       = 10
+	testLifeLength = 3 * time.Second
+
+	// benchLifeLength is the length of the lives run by the benchmark, which
+	// are long enough for the AI to grow and be hunted.
+	benchLifeLength = time.Minute
+)
+
+func testStrategies(t testing.TB) []namedStrategy {
+	strategies, err := parseStrategies(defaultStrategies)
+	if err != nil {
+		t.Fatalf("parsing the default strategies: %s", err)
+	}
+	return strategies
+}
+
+func TestSimulatedLives(t *testing.T) {
+	results := simulateLives(1, testLives, testNPCs, testLifeLength, testStrategies(t))
+	if len(results) != testLives {
+		t.Fatalf("got %d lives, want %d", len(results), testLives)
+	}
+
+	for i, r := range results {
+		if r.Duration <= 0 || r.Duration > testLifeLength {
+			t.Errorf("life %d lasted %s, want between 0 and %s", i, r.Duration, testLifeLength)
+		}
+		if !r.Died && r.Duration != testLifeLength {
+			t.Errorf("life %d ended after %s without dying", i, r.Duration)
+		}
+		if r.PeakMass < startMass {
+			t.Errorf("life %d peaked at %.1f mass, want at least the starting %d", i, r.PeakMass, startMass)
+		}
+	}
+}
+
+func TestSimulationIsDeterministic(t *testing.T) {
+	strategies := testStrategies(t)
+
+	first := simulateLives(7, 2, testNPCs, testLifeLength, strategies)
+	second := simulateLives(7, 2, testNPCs, testLifeLength, strategies)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("the same seed played out differently:\n%+v\n%+v", first, second)
+	}
+}
+
+// newTestAI returns an AI that controls own and can see others.
+func newTestAI(t *testing.T, own *agario.Cell, others ...*agario.Cell) *AI {
+	g := &agario.Game{
+		Cells: map[uint32]*agario.Cell{own.ID: own},
+		MyIDs: map[uint32]struct{}{own.ID: {}},
+	}
+	g.Board.Right = worldSize
+	g.Board.Bottom = worldSize
+
+	for _, c := range others {
+		g.Cells[c.ID] = c
+	}
+
+	return &AI{
+		g: g,
+		c: &simCommander{&worldPlayer{}},
+
+		Strategies: testStrategies(t),
+	}
+}
+
+func TestStrategySelection(t *testing.T) {
+	center := mgl32.Vec2{worldSize / 2, worldSize / 2}
+	at := func(dx, dy float32) mgl32.Vec2 {
+		return center.Add(mgl32.Vec2{dx, dy})
+	}
+
+	tests := []struct {
+		name   string
+		own    *agario.Cell
+		others []*agario.Cell
+		want   []string
+	}{
+		{
+			name: "nothing in view",
+			own:  &agario.Cell{ID: 1, Size: 40, Position: center},
+			want: []string{"wander"},
+		},
+		{
+			name: "food in view",
+			own:  &agario.Cell{ID: 1, Size: 40, Position: center},
+			others: []*agario.Cell{
+				{ID: 2, Size: 10, Position: at(300, 0)},
+			},
+			want: []string{"feed"},
+		},
+		{
+			name: "predator next to us",
+			own:  &agario.Cell{ID: 1, Size: 40, Position: center},
+			others: []*agario.Cell{
+				{ID: 2, Size: 10, Position: at(300, 0)},
+				{ID: 3, Name: "Predator", Size: 200, Position: at(-150, 0)},
+			},
+			want: []string{"flee"},
+		},
+		{
+			name: "prey next to us",
+			own:  &agario.Cell{ID: 1, Size: 100, Position: center},
+			others: []*agario.Cell{
+				{ID: 2, Size: 10, Position: at(300, 0)},
+				{ID: 3, Name: "Prey", Size: 30, Position: at(200, 0)},
+			},
+			want: []string{"hunt", "chase"},
+		},
+	}
+
+	for _, test := range tests {
+		ai := newTestAI(t, test.own, test.others...)
+		ai.Update(simStep)
+
+		found := false
+		for _, want := range test.want {
+			if ai.Strategy == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: chose %q, want one of %q", test.name, ai.Strategy, test.want)
+		}
+	}
+}
+
+func BenchmarkSimulatedLife(b *testing.B) {
+	strategies := testStrategies(b)
+
+	for i := 0; i < b.N; i++ {
+		simulateLives(int64(i), 1, testNPCs, benchLifeLength, strategies)
+	}
+}
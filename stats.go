@@ -0,0 +1,103 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nightexcessive/agario"
+)
+
+// lifeResult describes how a single life went.
+type lifeResult struct {
+	Duration time.Duration
+	PeakMass float32
+	Died     bool
+}
+
+// lifeTracker keeps track of every life of a player.
+type lifeTracker struct {
+	lives   []lifeResult
+	current *lifeResult
+}
+
+// Update records dt of time passing while the player is alive or dead with the
+// given mass.
+func (t *lifeTracker) Update(dt time.Duration, alive bool, mass float32) {
+	if !alive {
+		if t.current != nil {
+			t.current.Died = true
+			t.End()
+		}
+		return
+	}
+
+	if t.current == nil {
+		t.current = &lifeResult{}
+	}
+
+	t.current.Duration += dt
+	if mass > t.current.PeakMass {
+		t.current.PeakMass = mass
+	}
+}
+
+// End ends the current life without the player dying, such as when the
+// connection is lost.
+func (t *lifeTracker) End() {
+	if t.current == nil {
+		return
+	}
+
+	t.lives = append(t.lives, *t.current)
+	t.current = nil
+}
+
+// Lives returns every life so far, including the current one.
+func (t *lifeTracker) Lives() []lifeResult {
+	lives := append([]lifeResult(nil), t.lives...)
+	if t.current != nil {
+		lives = append(lives, *t.current)
+	}
+	return lives
+}
+
+// ownMass returns the total mass of our cells in g. The squared sizes are
+// summed as integers so that the result doesn't depend on the map order.
+func ownMass(g *agario.Game) float32 {
+	var sizes int64
+	for id := range g.MyIDs {
+		if cell, ok := g.Cells[id]; ok {
+			sizes += int64(cell.Size) * int64(cell.Size)
+		}
+	}
+	return float32(sizes) / 100
+}
+
+// logLives logs how a set of lives went.
+func logLives(logf func(format string, v ...interface{}), results []lifeResult) {
+	n := len(results)
+	if n == 0 {
+		logf("Lives: 0")
+		return
+	}
+
+	var (
+		deaths    int
+		totalTime time.Duration
+		totalPeak float32
+		bestPeak  float32
+	)
+	for _, r := range results {
+		if r.Died {
+			deaths++
+		}
+		totalTime += r.Duration
+		totalPeak += r.PeakMass
+		if r.PeakMass > bestPeak {
+			bestPeak = r.PeakMass
+		}
+	}
+
+	logf("Lives: %d (%d deaths)", n, deaths)
+	logf("Time alive: %s total, %s average", totalTime, totalTime/time.Duration(n))
+	logf("Peak mass: %.0f average, %.0f best", totalPeak/float32(n), bestPeak)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Action performs the behaviour that a Strategy decided on.
+type Action func()
+
+// Strategy is a single behaviour that the AI can choose to perform on a tick.
+type Strategy interface {
+	// Evaluate inspects the AI's view of the game and returns how urgently the
+	// strategy wants to act, along with the action it would take. A nil action
+	// means that the strategy doesn't apply this tick.
+	Evaluate(ai *AI) (priority float64, action Action)
+}
+
+// StrategyFunc adapts an ordinary function to the Strategy interface.
+type StrategyFunc func(ai *AI) (float64, Action)
+
+// Evaluate calls f(ai).
+func (f StrategyFunc) Evaluate(ai *AI) (float64, Action) {
+	return f(ai)
+}
+
+// Priorities returned by the built-in strategies. Strategies that return the
+// same priority are ordered by their position in the strategy list.
+const (
+	priorityNormal = 1
+	priorityUrgent = 2
+)
+
+// defaultStrategies is the strategy list used when none is given.
+const defaultStrategies = "hide,flee,recombine,virusshot,hunt,chase,scavenge,feed,wander"
+
+var strategyRegistry = make(map[string]Strategy)
+
+func init() {
+	RegisterStrategy("hide", StrategyFunc((*AI).hide))
+	RegisterStrategy("flee", StrategyFunc((*AI).flee))
+	RegisterStrategy("recombine", StrategyFunc((*AI).recombine))
+	RegisterStrategy("virusshot", StrategyFunc((*AI).shootVirus))
+	RegisterStrategy("hunt", StrategyFunc((*AI).hunt))
+	RegisterStrategy("chase", StrategyFunc((*AI).chase))
+	RegisterStrategy("scavenge", StrategyFunc((*AI).scavenge))
+	RegisterStrategy("feed", StrategyFunc((*AI).feed))
+	RegisterStrategy("feedteam", StrategyFunc((*AI).feedTeammate))
+	RegisterStrategy("escort", StrategyFunc((*AI).escort))
+	RegisterStrategy("wander", StrategyFunc((*AI).wander))
+}
+
+// RegisterStrategy makes a strategy available under the given name. It panics
+// if the name is already taken.
+func RegisterStrategy(name string, s Strategy) {
+	if _, exists := strategyRegistry[name]; exists {
+		panic("strategy " + name + " registered twice")
+	}
+
+	strategyRegistry[name] = s
+}
+
+// strategyNames returns the names of every registered strategy in sorted order.
+func strategyNames() []string {
+	names := make([]string, 0, len(strategyRegistry))
+	for name := range strategyRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type namedStrategy struct {
+	Name string
+	Strategy
+}
+
+// parseStrategies turns a comma separated list of strategy names into the
+// strategies that they refer to, keeping the order of the list.
+func parseStrategies(list string) ([]namedStrategy, error) {
+	var strategies []namedStrategy
+	seen := make(map[string]struct{})
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		s, ok := strategyRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strings.Join(strategyNames(), ", "))
+		}
+		if _, dup := seen[name]; dup {
+			return nil, fmt.Errorf("strategy %q listed more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		strategies = append(strategies, namedStrategy{name, s})
+	}
+
+	if len(strategies) == 0 {
+		return nil, fmt.Errorf("no strategies given (available: %s)", strings.Join(strategyNames(), ", "))
+	}
+
+	return strategies, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+type team byte
+
+const (
+	teamNone team = iota
+	teamRed
+	teamGreen
+	teamBlue
+)
+
+const (
+	// teamColorMargin is how much stronger than the other two a colour
+	// channel has to be for a cell to count as being on that channel's team.
+	teamColorMargin = 0x40
+
+	// teammateFeedRatio is how many times larger than our largest cell a
+	// teammate has to be before we feed it.
+	teammateFeedRatio = 2
+	// escortDistance is how far from a teammate's edge we try to stay while
+	// escorting it.
+	escortDistance = 300
+)
+
+// cellTeam infers the team of a cell from its colour. In teams mode every
+// player's cells are tinted towards the colour of their team.
+func cellTeam(cell *agario.Cell) team {
+	r, g, b, _ := cell.Color.RGBA()
+	r, g, b = r&0xFF, g&0xFF, b&0xFF
+
+	switch {
+	case r >= g+teamColorMargin && r >= b+teamColorMargin:
+		return teamRed
+	case g >= r+teamColorMargin && g >= b+teamColorMargin:
+		return teamGreen
+	case b >= r+teamColorMargin && b >= g+teamColorMargin:
+		return teamBlue
+	}
+
+	return teamNone
+}
+
+// isTeammate reports whether cell belongs to a player on our team.
+func (ai *AI) isTeammate(cell *agario.Cell) bool {
+	return ai.Teams && ai.Team != teamNone && cellTeam(cell) == ai.Team
+}
+
+// getLargeTeammate returns the closest teammate that is at least ratio times
+// larger than our largest cell.
+func (ai *AI) getLargeTeammate(ratio float32) *agario.Cell {
+	minSize := float32(ai.getLargestOwnCell().Size) * ratio
+
+	return ai.getClosestFiltered(ai.Me.Position, ai.Teammates, func(cell *agario.Cell) bool {
+		return float32(cell.Size) >= minSize
+	})
+}
+
+// feedTeammate ejects mass into a nearby teammate that is much larger than us
+func (ai *AI) feedTeammate() (float64, Action) {
+	if !ai.Teams {
+		ai.addStatusMessage("Not feeding teammates: Not playing teams")
+		return 0, nil
+	}
+	if !ai.canEject() {
+		ai.addStatusMessage("Not feeding teammates: Too small to eject")
+		return 0, nil
+	}
+
+	teammate := ai.getLargeTeammate(teammateFeedRatio)
+	if teammate == nil {
+		ai.addStatusMessage("Not feeding teammates: No large teammates")
+		return 0, nil
+	}
+
+	dist := teammate.Position.Sub(ai.Me.Position).Len() - float32(ai.getLargestOwnCell().Size)
+	if dist > ejectRange+float32(teammate.Size) {
+		ai.addStatusMessage("Not feeding teammates: Out of range of " + prettyCellName(teammate))
+		return 0, nil
+	}
+
+	return priorityNormal, func() {
+		ai.addStatusMessage("Feeding teammate " + prettyCellName(teammate))
+		ai.ejectTowards(teammate.Position)
+		ai.State = stateFeeding
+	}
+}
+
+// escort stays close to the nearest teammate that is much larger than us
+func (ai *AI) escort() (float64, Action) {
+	if !ai.Teams {
+		ai.addStatusMessage("Not escorting: Not playing teams")
+		return 0, nil
+	}
+
+	teammate := ai.getLargeTeammate(teammateFeedRatio)
+	if teammate == nil {
+		ai.addStatusMessage("Not escorting: No large teammates")
+		return 0, nil
+	}
+
+	return priorityNormal, func() {
+		ai.addStatusMessage("Escorting " + prettyCellName(teammate))
+		delta := ai.Me.Position.Sub(teammate.Position)
+		if delta.Len() == 0 {
+			delta = mgl32.Vec2{1, 0}
+		}
+		ai.movePathed(teammate.Position.Add(delta.Normalize().Mul(float32(teammate.Size) + escortDistance)))
+		ai.State = stateIdle
+	}
+}
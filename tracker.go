@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// trackerHistorySize is the number of samples remembered per cell.
+	trackerHistorySize = 16
+
+	// Time constants of the exponential smoothing applied to the estimates.
+	velocitySmoothing     = 200 * time.Millisecond
+	accelerationSmoothing = 400 * time.Millisecond
+	massRateSmoothing     = 500 * time.Millisecond
+)
+
+type cellSample struct {
+	At       time.Duration
+	Position mgl32.Vec2
+	Size     int32
+}
+
+// trackedCell is the motion history of a single cell.
+type trackedCell struct {
+	ID uint32
+
+	// History holds the most recent samples of the cell, oldest first.
+	History []cellSample
+
+	// Velocity is the smoothed velocity of the cell in distance per second.
+	Velocity mgl32.Vec2
+	// Acceleration is the smoothed acceleration of the cell in distance per
+	// second squared.
+	Acceleration mgl32.Vec2
+	// MassRate is the smoothed change of the cell's mass per second.
+	MassRate float32
+
+	FirstSeen time.Duration
+	LastSeen  time.Duration
+}
+
+// Latest returns the most recent sample of the cell.
+func (c *trackedCell) Latest() cellSample {
+	return c.History[len(c.History)-1]
+}
+
+// PredictPosition extrapolates where the cell will be in the given number of
+// seconds if it keeps moving the way it has been.
+func (c *trackedCell) PredictPosition(seconds float32) mgl32.Vec2 {
+	return c.Latest().Position.Add(c.Velocity.Mul(seconds)).Add(c.Acceleration.Mul(0.5 * seconds * seconds))
+}
+
+func (c *trackedCell) add(s cellSample) {
+	prev := c.Latest()
+	elapsed := s.At - prev.At
+	if elapsed <= 0 {
+		return
+	}
+	dt := float32(elapsed.Seconds())
+
+	velocity := s.Position.Sub(prev.Position).Mul(1 / dt)
+	newVelocity := lerpVec2(c.Velocity, velocity, smoothingFactor(elapsed, velocitySmoothing))
+
+	if len(c.History) > 1 {
+		acceleration := newVelocity.Sub(c.Velocity).Mul(1 / dt)
+		c.Acceleration = lerpVec2(c.Acceleration, acceleration, smoothingFactor(elapsed, accelerationSmoothing))
+	}
+	c.Velocity = newVelocity
+
+	massRate := (sizeToMass(s.Size) - sizeToMass(prev.Size)) / dt
+	c.MassRate += (massRate - c.MassRate) * smoothingFactor(elapsed, massRateSmoothing)
+
+	c.History = append(c.History, s)
+	if len(c.History) > trackerHistorySize {
+		c.History = c.History[len(c.History)-trackerHistorySize:]
+	}
+	c.LastSeen = s.At
+}
+
+// tracker remembers the recent motion of every visible cell. Cells are
+// forgotten as soon as they leave our view or are eaten.
+type tracker struct {
+	cells map[uint32]*trackedCell
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		cells: make(map[uint32]*trackedCell),
+	}
+}
+
+// Update records the current state of cells and evicts the cells that are no
+// longer visible.
+func (t *tracker) Update(now time.Duration, cells map[uint32]*agario.Cell) {
+	for id, cell := range cells {
+		s := cellSample{now, cell.Position, cell.Size}
+
+		tc, ok := t.cells[id]
+		if !ok {
+			t.cells[id] = &trackedCell{
+				ID:      id,
+				History: []cellSample{s},
+
+				FirstSeen: now,
+				LastSeen:  now,
+			}
+			continue
+		}
+
+		tc.add(s)
+	}
+
+	for id := range t.cells {
+		if _, ok := cells[id]; !ok {
+			delete(t.cells, id)
+		}
+	}
+}
+
+// Get returns the history of the cell with the given ID, or nil if it isn't
+// being tracked.
+func (t *tracker) Get(id uint32) *trackedCell {
+	return t.cells[id]
+}
+
+// Velocity returns the smoothed velocity of a cell, or zero if it hasn't been
+// seen for long enough to tell.
+func (t *tracker) Velocity(id uint32) mgl32.Vec2 {
+	tc := t.cells[id]
+	if tc == nil {
+		return mgl32.Vec2{}
+	}
+	return tc.Velocity
+}
+
+// PredictPosition returns where cell is expected to be in the given number of
+// seconds.
+func (t *tracker) PredictPosition(cell *agario.Cell, seconds float32) mgl32.Vec2 {
+	tc := t.cells[cell.ID]
+	if tc == nil {
+		return cell.Position
+	}
+	return tc.PredictPosition(seconds)
+}
+
+// smoothingFactor returns the weight given to a new sample taken elapsed after
+// the previous one, for an exponential moving average with time constant tau.
+func smoothingFactor(elapsed, tau time.Duration) float32 {
+	return float32(1 - math.Exp(-elapsed.Seconds()/tau.Seconds()))
+}
+
+func lerpVec2(a, b mgl32.Vec2, t float32) mgl32.Vec2 {
+	return a.Add(b.Sub(a).Mul(t))
+}
+
+// sizeToMass converts a cell's size (its radius) to its mass.
+func sizeToMass(size int32) float32 {
+	return float32(size) * float32(size) / 100
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+// virusPadding is added around viruses that we must not touch.
+const virusPadding = 50
+
+// poppedBy reports whether cell is large enough to be split by the virus.
+func (ai *AI) poppedBy(cell, virus *agario.Cell) bool {
+	return float32(cell.Size) >= float32(virus.Size)*ai.params.EatSizeRequirement
+}
+
+// addVirusCosts marks the viruses that would split us as impassable. While our
+// smallest cell is too small to be split, viruses are harmless and are left
+// free to cross.
+func (ai *AI) addVirusCosts() {
+	for _, virus := range ai.Viruses {
+		if !ai.poppedBy(ai.SmallestOwnCell, virus) {
+			continue
+		}
+
+		x, y := gameToCostMap(virus.Position.Elem())
+
+		size := (int(ai.SmallestOwnCell.Size) + virusPadding) / costMapReduction
+		setCostMapCircle(ai.Map, x, y, size, costDoNotPass)
+		setCostMapCircle(ai.Map, x+1, y+1, size, costDoNotPass)
+	}
+}
+
+// hide uses a virus as cover from predators that are too big to touch it
+func (ai *AI) hide() (float64, Action) {
+	if len(ai.Viruses) == 0 {
+		ai.addStatusMessage("Not hiding: No known viruses")
+		return 0, nil
+	}
+
+	threats := ai.Threats
+	if len(threats) == 0 {
+		ai.addStatusMessage("Not hiding: No dangerous predators nearby")
+		return 0, nil
+	}
+
+	largestOwnCell := ai.getLargestOwnCell()
+
+	var (
+		cover     *agario.Cell
+		coverCost = math.MaxFloat64
+	)
+	for _, virus := range ai.Viruses {
+		if ai.poppedBy(largestOwnCell, virus) {
+			continue
+		}
+
+		// The virus only protects us if every threat would be split by it
+		protects := true
+		for _, t := range threats {
+			if !ai.poppedBy(t.Cell, virus) {
+				protects = false
+				break
+			}
+		}
+		if !protects {
+			continue
+		}
+
+		if ai.groupClearance(virus.Position, threats) < 0 {
+			// We'd be intercepted on the way there
+			continue
+		}
+
+		cost := ai.DijkstraMap.WeightTo(ai.Map.GetNode(gameToCostMap(virus.Position.Elem())))
+		if cover == nil || cost < coverCost {
+			cover = virus
+			coverCost = cost
+		}
+	}
+	if cover == nil {
+		ai.addStatusMessage("Not hiding: No reachable virus gives cover")
+		return 0, nil
+	}
+
+	priority := float64(priorityNormal)
+	if ai.stationaryClearance(threats) < 0 {
+		priority = priorityUrgent
+	}
+
+	return priority, func() {
+		ai.addStatusMessage("Hiding behind a virus")
+		if dist2(ai.Me.Position, cover.Position) < square(float32(cover.Size)) {
+			// Already in cover. Stay on top of the virus.
+			ai.Path = []mgl32.Vec2{ai.Me.Position, cover.Position}
+			ai.setTargetPos(cover.Position)
+		} else {
+			ai.movePathed(cover.Position)
+		}
+		ai.State = stateFleeing
+	}
+}
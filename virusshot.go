@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/nightexcessive/agario"
+)
+
+const (
+	// virusShotsToSplit is the number of ejects that a virus has to be fed
+	// before it splits.
+	virusShotsToSplit = 7
+	// virusShotRange is how far a virus that has been split off travels.
+	virusShotRange = 800
+	// virusShotTravelTime is roughly how long a split off virus takes to
+	// reach the end of its range.
+	virusShotTravelTime = 500 * time.Millisecond
+	// virusShotMinRatio is how many times larger than our largest cell a
+	// predator has to be before we try to shoot a virus at it.
+	virusShotMinRatio = 1.5
+
+	// ejectRange is how far past the edge of our cell ejected mass travels.
+	ejectRange = 400
+	// virusShotStandoff is the gap kept between us and the virus we're
+	// feeding.
+	virusShotStandoff = 50
+
+	// virusShotAimTolerance is the smallest cosine of the angle between the
+	// line we're aiming along and the line to the predator that still counts
+	// as lined up.
+	virusShotAimTolerance = 0.95
+)
+
+// virusShot is a virus shot in progress.
+type virusShot struct {
+	Virus    *agario.Cell
+	Predator *agario.Cell
+
+	// Aim is the direction from the virus to where the predator was going to
+	// be when we lined up on it.
+	Aim mgl32.Vec2
+
+	// VirusSize is the largest size that the virus has grown to while we
+	// fed it. It shrinks back once it splits.
+	VirusSize  int32
+	ShotsFired int
+}
+
+// aimVirusShot returns the direction from virus towards where predator will
+// be by the time a split off virus reaches it.
+func (ai *AI) aimVirusShot(virus, predator *agario.Cell) mgl32.Vec2 {
+	target := ai.tracker.PredictPosition(predator, float32(virusShotTravelTime.Seconds()))
+	delta := target.Sub(virus.Position)
+	if delta.Len() == 0 {
+		return mgl32.Vec2{}
+	}
+	return delta.Normalize()
+}
+
+// virusShotPosition returns where we have to stand to feed virus along aim.
+func (ai *AI) virusShotPosition(virus *agario.Cell, aim mgl32.Vec2) mgl32.Vec2 {
+	standoff := float32(virus.Size+ai.getLargestOwnCell().Size) + virusShotStandoff
+	return virus.Position.Sub(aim.Mul(standoff))
+}
+
+// canShootVirusAt reports whether virus can be shot at predator, and if not,
+// why.
+func (ai *AI) canShootVirusAt(virus, predator *agario.Cell) (bool, string) {
+	largest := ai.getLargestOwnCell()
+
+	switch {
+	case float32(predator.Size) < float32(largest.Size)*virusShotMinRatio:
+		return false, "predator isn't large enough"
+	case !ai.poppedBy(predator, virus):
+		return false, "predator is too small to be split"
+	case dist2(virus.Position, predator.Position) > square(virusShotRange+float32(predator.Size)):
+		return false, "predator is out of range"
+	}
+
+	return true, ""
+}
+
+// findVirusShot looks for the virus and predator pair that we can get lined
+// up on the quickest.
+func (ai *AI) findVirusShot() *virusShot {
+	var (
+		best     *virusShot
+		bestDist float32
+	)
+	for _, virus := range ai.Viruses {
+		if ai.poppedBy(ai.getLargestOwnCell(), virus) {
+			// We'd be split ourselves if we got it wrong
+			continue
+		}
+
+		for _, predator := range ai.Predators {
+			if ok, _ := ai.canShootVirusAt(virus, predator); !ok {
+				continue
+			}
+
+			aim := ai.aimVirusShot(virus, predator)
+			if dist := dist2(ai.Me.Position, ai.virusShotPosition(virus, aim)); best == nil || dist < bestDist {
+				best = &virusShot{
+					Virus:    virus,
+					Predator: predator,
+
+					Aim: aim,
+
+					VirusSize: virus.Size,
+				}
+				bestDist = dist
+			}
+		}
+	}
+
+	return best
+}
+
+// shootVirus feeds a virus until it splits towards a predator that is much
+// larger than us
+func (ai *AI) shootVirus() (float64, Action) {
+	if !ai.canEject() {
+		ai.virusShot = nil
+		ai.addStatusMessage("Not shooting virus: Too small to eject")
+		return 0, nil
+	}
+
+	shot := ai.virusShot
+	if shot != nil {
+		virus, virusOk := ai.g.Cells[shot.Virus.ID]
+		predator, predatorOk := ai.g.Cells[shot.Predator.ID]
+
+		var reason string
+		switch {
+		case !virusOk:
+			reason = "virus is gone"
+		case !predatorOk:
+			reason = "predator is gone"
+		case shot.ShotsFired >= virusShotsToSplit && virus.Size < shot.VirusSize:
+			ai.addStatusMessage("Shot virus at " + prettyCellName(predator))
+			ai.virusShot = nil
+			shot = nil
+		case shot.ShotsFired >= virusShotsToSplit+2:
+			reason = "virus didn't split"
+		default:
+			aim := ai.aimVirusShot(virus, predator)
+			if aim.Dot(shot.Aim) < virusShotAimTolerance {
+				reason = "predator moved out of line"
+				break
+			}
+			if ok, why := ai.canShootVirusAt(virus, predator); !ok {
+				reason = why
+				break
+			}
+
+			shot.Virus, shot.Predator = virus, predator
+			if virus.Size > shot.VirusSize {
+				shot.VirusSize = virus.Size
+			}
+		}
+
+		if reason != "" {
+			ai.addStatusMessage("Cancelled virus shot: " + reason)
+			ai.virusShot = nil
+			shot = nil
+		}
+	}
+
+	if shot == nil {
+		shot = ai.findVirusShot()
+	}
+	if shot == nil {
+		ai.addStatusMessage("Not shooting virus: No predator behind a virus")
+		return 0, nil
+	}
+
+	return priorityNormal, func() {
+		ai.virusShot = shot
+		ai.State = stateHunting
+
+		position := ai.virusShotPosition(shot.Virus, shot.Aim)
+		lineUp := shot.Virus.Position.Sub(ai.Me.Position)
+		inRange := lineUp.Len()-float32(ai.getLargestOwnCell().Size) <= ejectRange
+		if !inRange || lineUp.Len() == 0 || lineUp.Normalize().Dot(shot.Aim) < virusShotAimTolerance {
+			ai.addStatusMessage("Lining up virus shot at " + prettyCellName(shot.Predator))
+			ai.movePathed(position)
+			return
+		}
+
+		if ai.ejectTowards(shot.Virus.Position) {
+			shot.ShotsFired++
+		}
+		ai.addStatusMessage(fmt.Sprintf("Shooting virus at %s (%d/%d)", prettyCellName(shot.Predator), shot.ShotsFired, virusShotsToSplit))
+	}
+}
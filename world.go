@@ -0,0 +1,670 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	worldSize = 11180
+
+	worldFoodTarget  = 2000
+	worldVirusTarget = 30
+
+	foodMass     = 1
+	startMass    = 10
+	virusMass    = 100
+	ejectedMass  = 13
+	ejectCost    = 16
+	minSplitMass = 36
+	minEjectMass = 35
+	maxCells     = 16
+
+	// eatMassRatio is how many times more massive a cell has to be than
+	// another to eat it.
+	eatMassRatio = 1.25
+
+	// Impulses given to split cells and ejected mass, in distance per
+	// second. They decay by impulseDecay per second.
+	splitImpulse = 2400
+	ejectImpulse = 2000
+	virusImpulse = 1600
+	impulseDecay = 5
+
+	// massDecayRate is the fraction of mass lost per second by cells above
+	// massDecayMin.
+	massDecayRate = 0.002
+	massDecayMin  = 100
+
+	// A split cell can merge after mergeDelay plus mergeDelayPerMass for
+	// every unit of its mass.
+	mergeDelay        = 30 * time.Second
+	mergeDelayPerMass = 23 * time.Millisecond
+
+	// virusFeedsToSplit is how many times a virus has to be fed to split.
+	virusFeedsToSplit = 7
+
+	// Half of the area that a player of the smallest size can see.
+	viewHalfWidth  = 960
+	viewHalfHeight = 540
+
+	// npcRetarget is how often NPC players pick somewhere new to go.
+	npcRetarget = 3 * time.Second
+)
+
+type worldCellKind byte
+
+const (
+	kindFood worldCellKind = iota
+	kindPlayer
+	kindVirus
+	kindEjected
+)
+
+type worldCell struct {
+	ID    uint32
+	Kind  worldCellKind
+	Owner *worldPlayer
+
+	Position mgl32.Vec2
+	Impulse  mgl32.Vec2
+	Mass     float32
+	Color    color.RGBA
+
+	// MergeAt is when a player cell can merge with the player's other cells.
+	MergeAt time.Duration
+	// Feeds is the number of times a virus has been fed.
+	Feeds int
+}
+
+// Size returns the radius of the cell.
+func (c *worldCell) Size() float32 {
+	return float32(math.Sqrt(float64(c.Mass) * 100))
+}
+
+// speed returns how far a player cell moves per second.
+func (c *worldCell) speed() float32 {
+	return 30 * float32(math.Pow(float64(c.Mass), -1/4.5)) * serverTicksPerSecond
+}
+
+type worldPlayer struct {
+	Name  string
+	Color color.RGBA
+	Cells []*worldCell
+
+	Target mgl32.Vec2
+
+	wantSplit bool
+	wantEject bool
+
+	// NPC players are moved by the world itself.
+	NPC        bool
+	nextTarget time.Duration
+}
+
+// Alive reports whether the player has any cells left.
+func (p *worldPlayer) Alive() bool {
+	return len(p.Cells) > 0
+}
+
+// Mass returns the total mass of the player's cells.
+func (p *worldPlayer) Mass() (m float32) {
+	for _, c := range p.Cells {
+		m += c.Mass
+	}
+	return
+}
+
+// Center returns the mass weighted center of the player's cells.
+func (p *worldPlayer) Center() mgl32.Vec2 {
+	var (
+		center mgl32.Vec2
+		mass   float32
+	)
+	for _, c := range p.Cells {
+		center = center.Add(c.Position.Mul(c.Mass))
+		mass += c.Mass
+	}
+	if mass == 0 {
+		return center
+	}
+	return center.Mul(1 / mass)
+}
+
+// View returns the center and half extents of the area that the player can
+// see. Players see more of the board the larger they are. ok is false if the
+// player isn't alive.
+func (p *worldPlayer) View() (center mgl32.Vec2, halfW, halfH float32, ok bool) {
+	if !p.Alive() {
+		return mgl32.Vec2{}, 0, 0, false
+	}
+
+	var totalSize float32
+	for _, cell := range p.Cells {
+		totalSize += cell.Size()
+	}
+	scale := float32(math.Pow(math.Min(64/float64(totalSize), 1), 0.4))
+
+	return p.Center(), viewHalfWidth / scale, viewHalfHeight / scale, true
+}
+
+// inView reports whether any part of c is within the view centered on center.
+func (c *worldCell) inView(center mgl32.Vec2, halfW, halfH float32) bool {
+	d := c.Position.Sub(center)
+	size := c.Size()
+	return d.X()-size <= halfW && -d.X()-size <= halfW && d.Y()-size <= halfH && -d.Y()-size <= halfH
+}
+
+// Split requests that the player splits on the next step.
+func (p *worldPlayer) Split() {
+	p.wantSplit = true
+}
+
+// Eject requests that the player ejects mass on the next step.
+func (p *worldPlayer) Eject() {
+	p.wantEject = true
+}
+
+// worldEat records one cell eating another.
+type worldEat struct {
+	Eater, Victim uint32
+}
+
+// world is a simulation of the agar.io rules. Its rules are simplified, but
+// close enough to the real game for the AI to play in it.
+type world struct {
+	rand *rand.Rand
+
+	Width, Height float32
+
+	Clock   time.Duration
+	Cells   map[uint32]*worldCell
+	Players []*worldPlayer
+
+	// Eaten holds every cell eaten since it was last cleared.
+	Eaten []worldEat
+
+	nextID uint32
+}
+
+func newWorld(rng *rand.Rand) *world {
+	return &world{
+		rand: rng,
+
+		Width:  worldSize,
+		Height: worldSize,
+
+		Cells: make(map[uint32]*worldCell),
+	}
+}
+
+func (w *world) newID() uint32 {
+	// ID 0 terminates the node list of an update, so it's never used
+	w.nextID++
+	return w.nextID
+}
+
+// sortedCells returns every cell ordered by ID, so that the outcome of a step
+// only depends on the random number generator.
+func (w *world) sortedCells() []*worldCell {
+	cells := make([]*worldCell, 0, len(w.Cells))
+	for _, c := range w.Cells {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool { return cells[i].ID < cells[j].ID })
+	return cells
+}
+
+func (w *world) randomPosition() mgl32.Vec2 {
+	return mgl32.Vec2{w.rand.Float32() * w.Width, w.rand.Float32() * w.Height}
+}
+
+func (w *world) randomColor() color.RGBA {
+	// One channel is always bright and one always dark, as in the real game
+	c := [3]uint8{255, uint8(7 + w.rand.Intn(249)), 7}
+	w.rand.Shuffle(3, func(i, j int) { c[i], c[j] = c[j], c[i] })
+	return color.RGBA{c[0], c[1], c[2], 255}
+}
+
+func (w *world) addCell(kind worldCellKind, owner *worldPlayer, position mgl32.Vec2, mass float32, col color.RGBA) *worldCell {
+	c := &worldCell{
+		ID:    w.newID(),
+		Kind:  kind,
+		Owner: owner,
+
+		Position: position,
+		Mass:     mass,
+		Color:    col,
+	}
+	w.Cells[c.ID] = c
+
+	if owner != nil {
+		owner.Cells = append(owner.Cells, c)
+	}
+
+	return c
+}
+
+func (w *world) removeCell(c *worldCell) {
+	delete(w.Cells, c.ID)
+
+	if c.Owner == nil {
+		return
+	}
+	for i, oc := range c.Owner.Cells {
+		if oc == c {
+			c.Owner.Cells = append(c.Owner.Cells[:i], c.Owner.Cells[i+1:]...)
+			break
+		}
+	}
+}
+
+// AddPlayer adds a player that isn't playing yet.
+func (w *world) AddPlayer() *worldPlayer {
+	p := &worldPlayer{
+		Color: w.randomColor(),
+	}
+	w.Players = append(w.Players, p)
+	return p
+}
+
+// AddNPC adds a player that is moved by the world and respawns by itself.
+func (w *world) AddNPC(name string) *worldPlayer {
+	p := w.AddPlayer()
+	p.Name = name
+	p.NPC = true
+	return p
+}
+
+// RemovePlayer removes a player and all of its cells from the world.
+func (w *world) RemovePlayer(p *worldPlayer) {
+	for len(p.Cells) > 0 {
+		w.removeCell(p.Cells[0])
+	}
+
+	for i, op := range w.Players {
+		if op == p {
+			w.Players = append(w.Players[:i], w.Players[i+1:]...)
+			break
+		}
+	}
+}
+
+// Spawn gives a dead player a new cell. It does nothing if the player is
+// still alive.
+func (w *world) Spawn(p *worldPlayer, name string) {
+	if p.Alive() {
+		return
+	}
+
+	p.Name = name
+	c := w.addCell(kindPlayer, p, w.randomPosition(), startMass, p.Color)
+	p.Target = c.Position
+}
+
+// Step advances the world by dt.
+func (w *world) Step(dt time.Duration) {
+	w.Clock += dt
+	seconds := float32(dt.Seconds())
+
+	w.spawnPellets()
+
+	cells := w.sortedCells()
+	lastID := w.nextID
+
+	for _, p := range w.Players {
+		if p.NPC {
+			w.steerNPC(p, cells)
+		}
+
+		if p.wantSplit {
+			w.split(p)
+			p.wantSplit = false
+		}
+		if p.wantEject {
+			w.eject(p)
+			p.wantEject = false
+		}
+	}
+
+	// IDs only ever increase, so the cells added since are already in order
+	for id := lastID + 1; id <= w.nextID; id++ {
+		if c, ok := w.Cells[id]; ok {
+			cells = append(cells, c)
+		}
+	}
+
+	w.move(seconds, cells)
+	w.eat(cells)
+	w.merge()
+	w.decay(seconds)
+}
+
+func (w *world) spawnPellets() {
+	var food, viruses int
+	for _, c := range w.Cells {
+		switch c.Kind {
+		case kindFood:
+			food++
+		case kindVirus:
+			viruses++
+		}
+	}
+
+	for ; food < worldFoodTarget; food++ {
+		w.addCell(kindFood, nil, w.randomPosition(), foodMass, w.randomColor())
+	}
+	for ; viruses < worldVirusTarget; viruses++ {
+		w.addCell(kindVirus, nil, w.randomPosition(), virusMass, color.RGBA{0x33, 0xff, 0x33, 255})
+	}
+}
+
+// steerNPC moves an NPC towards the closest cell it can eat, or somewhere
+// random when there is none nearby. cells must be ordered by ID.
+func (w *world) steerNPC(p *worldPlayer, cells []*worldCell) {
+	if !p.Alive() {
+		w.Spawn(p, p.Name)
+		return
+	}
+
+	center := p.Center()
+	smallest := p.Cells[0]
+	for _, c := range p.Cells {
+		if c.Mass < smallest.Mass {
+			smallest = c
+		}
+	}
+
+	const sight = 600
+	var (
+		closest     *worldCell
+		closestDist float32
+	)
+	for _, c := range cells {
+		if c.Owner == p || c.Kind == kindVirus || smallest.Mass < c.Mass*eatMassRatio {
+			continue
+		}
+		if dist := c.Position.Sub(center).Len(); dist < sight && (closest == nil || dist < closestDist) {
+			closest = c
+			closestDist = dist
+		}
+	}
+
+	if closest != nil {
+		p.Target = closest.Position
+		return
+	}
+
+	if w.Clock >= p.nextTarget {
+		p.Target = w.randomPosition()
+		p.nextTarget = w.Clock + npcRetarget
+	}
+}
+
+func (w *world) mergeCooldown(mass float32) time.Duration {
+	return mergeDelay + time.Duration(mass*float32(mergeDelayPerMass))
+}
+
+func (w *world) split(p *worldPlayer) {
+	for _, c := range append([]*worldCell(nil), p.Cells...) {
+		if len(p.Cells) >= maxCells {
+			return
+		}
+		if c.Mass < minSplitMass {
+			continue
+		}
+
+		dir := p.Target.Sub(c.Position)
+		if dir.Len() == 0 {
+			dir = mgl32.Vec2{1, 0}
+		}
+		dir = dir.Normalize()
+
+		c.Mass /= 2
+		c.MergeAt = w.Clock + w.mergeCooldown(c.Mass)
+
+		piece := w.addCell(kindPlayer, p, c.Position, c.Mass, c.Color)
+		piece.Impulse = dir.Mul(splitImpulse)
+		piece.MergeAt = c.MergeAt
+	}
+}
+
+func (w *world) eject(p *worldPlayer) {
+	for _, c := range p.Cells {
+		if c.Mass < minEjectMass {
+			continue
+		}
+
+		dir := p.Target.Sub(c.Position)
+		if dir.Len() == 0 {
+			dir = mgl32.Vec2{1, 0}
+		}
+		dir = dir.Normalize()
+
+		c.Mass -= ejectCost
+
+		blob := w.addCell(kindEjected, nil, c.Position.Add(dir.Mul(c.Size())), ejectedMass, c.Color)
+		blob.Impulse = dir.Mul(ejectImpulse)
+	}
+}
+
+func (w *world) clamp(p mgl32.Vec2) mgl32.Vec2 {
+	return mgl32.Vec2{
+		mgl32.Clamp(p.X(), 0, w.Width),
+		mgl32.Clamp(p.Y(), 0, w.Height),
+	}
+}
+
+// move moves every cell. cells must be ordered by ID.
+func (w *world) move(seconds float32, cells []*worldCell) {
+	decay := float32(math.Exp(-impulseDecay * float64(seconds)))
+
+	for _, c := range cells {
+		if c.Kind == kindPlayer {
+			delta := c.Owner.Target.Sub(c.Position)
+			if dist := delta.Len(); dist > 0 {
+				step := c.speed() * seconds
+				if step > dist {
+					step = dist
+				}
+				c.Position = c.Position.Add(delta.Mul(step / dist))
+			}
+		}
+
+		if c.Impulse.Len() > 1 {
+			c.Position = c.Position.Add(c.Impulse.Mul(seconds))
+			c.Impulse = c.Impulse.Mul(decay)
+		} else {
+			c.Impulse = mgl32.Vec2{}
+		}
+
+		c.Position = w.clamp(c.Position)
+	}
+
+	// Cells of the same player that can't merge yet push each other apart
+	for _, p := range w.Players {
+		for i, a := range p.Cells {
+			for _, b := range p.Cells[i+1:] {
+				if w.Clock >= a.MergeAt && w.Clock >= b.MergeAt {
+					continue
+				}
+
+				delta := b.Position.Sub(a.Position)
+				dist := delta.Len()
+				overlap := a.Size() + b.Size() - dist
+				if overlap <= 0 || dist == 0 {
+					continue
+				}
+
+				push := delta.Mul(overlap / dist / 2)
+				a.Position = w.clamp(a.Position.Sub(push))
+				b.Position = w.clamp(b.Position.Add(push))
+			}
+		}
+	}
+}
+
+// canEat reports whether a can eat b.
+func (w *world) canEat(a, b *worldCell) bool {
+	if a.Owner != nil && a.Owner == b.Owner {
+		return false
+	}
+	if a.Mass < b.Mass*eatMassRatio {
+		return false
+	}
+
+	return a.Position.Sub(b.Position).Len() < a.Size()-b.Size()/3
+}
+
+// eat lets every cell eat the cells it can. cells must be ordered by ID.
+func (w *world) eat(cells []*worldCell) {
+	// Larger cells eat first, so that a cell isn't eaten by something that
+	// was eaten itself this step
+	eaters := make([]*worldCell, 0, len(cells))
+	for _, c := range cells {
+		if c.Kind == kindPlayer || c.Kind == kindVirus {
+			eaters = append(eaters, c)
+		}
+	}
+	sort.SliceStable(eaters, func(i, j int) bool {
+		if eaters[i].Mass == eaters[j].Mass {
+			return eaters[i].ID < eaters[j].ID
+		}
+		return eaters[i].Mass > eaters[j].Mass
+	})
+
+	for _, eater := range eaters {
+		if _, alive := w.Cells[eater.ID]; !alive {
+			continue
+		}
+
+		for _, victim := range cells {
+			if _, alive := w.Cells[victim.ID]; !alive || victim == eater {
+				continue
+			}
+
+			if eater.Kind == kindVirus {
+				// Viruses only eat ejected mass
+				if victim.Kind == kindEjected && eater.Position.Sub(victim.Position).Len() < eater.Size() {
+					w.feedVirus(eater, victim)
+				}
+				continue
+			}
+
+			if !w.canEat(eater, victim) {
+				continue
+			}
+
+			w.Eaten = append(w.Eaten, worldEat{eater.ID, victim.ID})
+			w.removeCell(victim)
+			eater.Mass += victim.Mass
+
+			if victim.Kind == kindVirus {
+				w.popCell(eater)
+			}
+		}
+	}
+}
+
+// feedVirus has virus eat a blob of ejected mass. Once it has been fed enough,
+// it splits a new virus off in the direction the blob was moving.
+func (w *world) feedVirus(virus, blob *worldCell) {
+	w.Eaten = append(w.Eaten, worldEat{virus.ID, blob.ID})
+	w.removeCell(blob)
+
+	virus.Feeds++
+	virus.Mass += ejectedMass
+	if virus.Feeds < virusFeedsToSplit {
+		return
+	}
+
+	dir := blob.Impulse
+	if dir.Len() == 0 {
+		dir = mgl32.Vec2{1, 0}
+	}
+
+	virus.Feeds = 0
+	virus.Mass = virusMass
+
+	shot := w.addCell(kindVirus, nil, virus.Position, virusMass, virus.Color)
+	shot.Impulse = dir.Normalize().Mul(virusImpulse)
+}
+
+// popCell splits a cell that ate a virus into as many pieces as the player is
+// allowed to have.
+func (w *world) popCell(c *worldCell) {
+	p := c.Owner
+	pieces := maxCells - len(p.Cells)
+	if limit := int(c.Mass/minSplitMass) - 1; pieces > limit {
+		pieces = limit
+	}
+	if pieces <= 0 {
+		return
+	}
+
+	c.Mass /= float32(pieces + 1)
+	c.MergeAt = w.Clock + w.mergeCooldown(c.Mass)
+
+	for i := 0; i < pieces; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(pieces)
+		dir := mgl32.Vec2{float32(math.Cos(angle)), float32(math.Sin(angle))}
+
+		piece := w.addCell(kindPlayer, p, c.Position, c.Mass, c.Color)
+		piece.Impulse = dir.Mul(splitImpulse)
+		piece.MergeAt = c.MergeAt
+	}
+}
+
+func (w *world) merge() {
+	for _, p := range w.Players {
+		for i := 0; i < len(p.Cells); i++ {
+			a := p.Cells[i]
+			for j := i + 1; j < len(p.Cells); j++ {
+				b := p.Cells[j]
+				if w.Clock < a.MergeAt || w.Clock < b.MergeAt {
+					continue
+				}
+				if a.Position.Sub(b.Position).Len() >= a.Size() {
+					continue
+				}
+
+				w.Eaten = append(w.Eaten, worldEat{a.ID, b.ID})
+				a.Mass += b.Mass
+				w.removeCell(b)
+				j--
+			}
+		}
+	}
+}
+
+func (w *world) decay(seconds float32) {
+	for _, c := range w.Cells {
+		if c.Kind == kindPlayer && c.Mass > massDecayMin {
+			c.Mass -= c.Mass * massDecayRate * seconds
+		}
+	}
+}
+
+// Leaderboard returns the players with the most mass, largest first.
+func (w *world) Leaderboard(n int) []*worldPlayer {
+	var alive []*worldPlayer
+	for _, p := range w.Players {
+		if p.Alive() {
+			alive = append(alive, p)
+		}
+	}
+
+	sort.SliceStable(alive, func(i, j int) bool {
+		return alive[i].Mass() > alive[j].Mass()
+	})
+
+	if len(alive) > n {
+		alive = alive[:n]
+	}
+	return alive
+}
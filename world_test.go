@@ -0,0 +1,127 @@
+package main
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func newTestWorld() *world {
+	return newWorld(rand.New(rand.NewSource(1)))
+}
+
+// addTestPlayer adds a player with a single cell of the given mass at pos.
+func (w *world) addTestPlayer(pos mgl32.Vec2, mass float32) *worldPlayer {
+	p := w.AddPlayer()
+	w.addCell(kindPlayer, p, pos, mass, p.Color)
+	p.Target = pos
+	return p
+}
+
+func TestWorldEat(t *testing.T) {
+	w := newTestWorld()
+	pos := mgl32.Vec2{1000, 1000}
+
+	eater := w.addTestPlayer(pos, 100)
+	victim := w.addTestPlayer(pos, 100/eatMassRatio)
+	w.eat(w.sortedCells())
+
+	if victim.Alive() {
+		t.Fatalf("a cell %v times smaller wasn't eaten", eatMassRatio)
+	}
+	if got, want := eater.Mass(), float32(100+100/eatMassRatio); got != want {
+		t.Errorf("eater has %.1f mass, want %.1f", got, want)
+	}
+	if len(w.Eaten) != 1 {
+		t.Errorf("recorded %d eaten cells, want 1", len(w.Eaten))
+	}
+
+	w = newTestWorld()
+	a := w.addTestPlayer(pos, 100)
+	b := w.addTestPlayer(pos, 90)
+	w.eat(w.sortedCells())
+
+	if !a.Alive() || !b.Alive() {
+		t.Errorf("a cell that is less than %v times larger ate another", eatMassRatio)
+	}
+}
+
+func TestWorldSplit(t *testing.T) {
+	w := newTestWorld()
+	p := w.addTestPlayer(mgl32.Vec2{1000, 1000}, 100)
+	p.Target = mgl32.Vec2{2000, 1000}
+
+	w.split(p)
+	if len(p.Cells) != 2 {
+		t.Fatalf("split into %d cells, want 2", len(p.Cells))
+	}
+	for _, c := range p.Cells {
+		if c.Mass != 50 {
+			t.Errorf("split cell has %.1f mass, want 50", c.Mass)
+		}
+		if want := w.Clock + w.mergeCooldown(50); c.MergeAt != want {
+			t.Errorf("split cell can merge at %s, want %s", c.MergeAt, want)
+		}
+	}
+	if piece := p.Cells[1]; piece.Impulse.X() <= 0 {
+		t.Errorf("split piece was launched with %v, want towards the target", piece.Impulse)
+	}
+
+	small := w.addTestPlayer(mgl32.Vec2{3000, 3000}, minSplitMass-1)
+	w.split(small)
+	if len(small.Cells) != 1 {
+		t.Errorf("a cell below %d mass split", minSplitMass)
+	}
+}
+
+func TestWorldVirusSplit(t *testing.T) {
+	w := newTestWorld()
+	pos := mgl32.Vec2{1000, 1000}
+	virus := w.addCell(kindVirus, nil, pos, virusMass, color.RGBA{})
+
+	feed := func() {
+		blob := w.addCell(kindEjected, nil, pos, ejectedMass, color.RGBA{})
+		blob.Impulse = mgl32.Vec2{1, 0}
+		w.feedVirus(virus, blob)
+	}
+
+	for i := 1; i < virusFeedsToSplit; i++ {
+		feed()
+	}
+	if got, want := virus.Mass, float32(virusMass+(virusFeedsToSplit-1)*ejectedMass); got != want {
+		t.Errorf("fed virus has %.1f mass, want %.1f", got, want)
+	}
+
+	feed()
+	var viruses int
+	for _, c := range w.Cells {
+		if c.Kind == kindVirus {
+			viruses++
+		}
+	}
+	if viruses != 2 {
+		t.Fatalf("%d viruses after feeding one %d times, want 2", viruses, virusFeedsToSplit)
+	}
+	if virus.Feeds != 0 || virus.Mass != virusMass {
+		t.Errorf("virus wasn't reset after splitting: %d feeds, %.1f mass", virus.Feeds, virus.Mass)
+	}
+}
+
+func TestWorldVirusPopsCell(t *testing.T) {
+	w := newTestWorld()
+	pos := mgl32.Vec2{1000, 1000}
+
+	p := w.addTestPlayer(pos, 200)
+	w.addCell(kindVirus, nil, pos, virusMass, color.RGBA{})
+	w.eat(w.sortedCells())
+
+	// 300 mass is enough for 8 pieces of at least minSplitMass
+	if len(p.Cells) != 8 {
+		t.Fatalf("popped into %d cells, want 8", len(p.Cells))
+	}
+	if got, want := p.Mass(), float32(200+virusMass); got < want-0.01 || got > want+0.01 {
+		t.Errorf("popped cells have %.1f mass, want %.1f", got, want)
+	}
+}